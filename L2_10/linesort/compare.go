@@ -0,0 +1,320 @@
+package linesort
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Comparator сравнивает строки по Options. Не меняется после создания,
+// поэтому им можно пользоваться из нескольких горутин.
+type Comparator struct {
+	keys       []Key // у каждого ключа задан Order
+	separator  string
+	lastResort bool // при равных ключах сравнивать строки целиком
+	reverse    bool
+}
+
+// NewComparator создает сравнение по ключам opts. Без ключей строки
+// сравниваются целиком в порядке opts.Order.
+func NewComparator(opts Options) *Comparator {
+	c := &Comparator{
+		separator:  opts.Separator,
+		lastResort: !opts.Stable && !opts.Unique,
+		reverse:    opts.Reverse,
+	}
+	if len(opts.Keys) == 0 {
+		order := opts.Order
+		c.keys = []Key{{Order: &order}}
+		return c
+	}
+	for _, k := range opts.Keys {
+		if k.Order == nil {
+			// ключ без модификаторов берет порядок из opts
+			order := opts.Order
+			k.Order = &order
+		}
+		c.keys = append(c.keys, k)
+	}
+	return c
+}
+
+// Compare возвращает отрицательное число, если a идет раньше b, ноль, если
+// строки равны, и положительное, если a идет позже. Ключи сравниваются в
+// порядке указания; при их равенстве строки сравниваются целиком, если не
+// заданы Stable или Unique.
+func (c *Comparator) Compare(a, b string) int {
+	for _, k := range c.keys {
+		if res := c.compareKey(k, a, b); res != 0 {
+			return res
+		}
+	}
+	if !c.lastResort {
+		return 0
+	}
+	res := strings.Compare(a, b)
+	if c.reverse {
+		return -res
+	}
+	return res
+}
+
+// сравнение строк по одному ключу с учетом его порядка
+func (c *Comparator) compareKey(k Key, a, b string) int {
+	a, b = c.extract(k, a), c.extract(k, b)
+	var res int
+	switch o := k.Order; {
+	case o.Month:
+		res = compareMonth(a, b)
+	case o.HumanNumeric:
+		res = compareHumanReadable(a, b)
+	case o.Numeric:
+		res = compareNumeric(a, b)
+	case o.Version:
+		res = compareVersion(a, b)
+	case o.Fold:
+		res = strings.Compare(strings.ToUpper(a), strings.ToUpper(b))
+	default:
+		res = strings.Compare(a, b)
+	}
+	if k.Order.Reverse {
+		return -res
+	}
+	return res
+}
+
+// extract возвращает часть строки, которую покрывает ключ
+func (c *Comparator) extract(k Key, s string) string {
+	if k.StartField == 0 {
+		return s
+	}
+	start, fieldEnd, ok := c.field(s, k.StartField)
+	if !ok {
+		return ""
+	}
+	if k.SkipStartBlanks {
+		start = skipBlanks(s, start, fieldEnd)
+	}
+	start = advance(s, start, fieldEnd, k.StartChar-1)
+
+	end := len(s)
+	if k.EndField > 0 {
+		if fieldStart, fieldEnd, ok := c.field(s, k.EndField); ok {
+			end = fieldEnd
+			if k.EndChar > 0 {
+				if k.SkipEndBlanks {
+					fieldStart = skipBlanks(s, fieldStart, fieldEnd)
+				}
+				end = advance(s, fieldStart, fieldEnd, k.EndChar)
+			}
+		}
+	}
+	if end < start {
+		return ""
+	}
+	return s[start:end]
+}
+
+// field возвращает границы поля n (с 1) с учетом разделителя
+func (c *Comparator) field(s string, n int) (start, end int, ok bool) {
+	if c.separator == "" {
+		return blankField(s, n)
+	}
+	for i := 1; ; i++ {
+		end = strings.Index(s[start:], c.separator)
+		if end < 0 {
+			end = len(s)
+		} else {
+			end += start
+		}
+		if i == n {
+			return start, end, true
+		}
+		if end == len(s) {
+			return 0, 0, false
+		}
+		start = end + len(c.separator)
+	}
+}
+
+// без разделителя поле начинается с пробелов, за которыми идут непробельные
+// символы; пробелы в начале поля относятся к нему, как в GNU sort
+func blankField(s string, n int) (start, end int, ok bool) {
+	for i := 1; ; i++ {
+		end = skipBlanks(s, start, len(s))
+		for end < len(s) && !isBlank(s[end]) {
+			end++
+		}
+		if i == n {
+			return start, end, true
+		}
+		if end == len(s) {
+			return 0, 0, false
+		}
+		start = end
+	}
+}
+
+// сдвиг на n символов, но не дальше limit
+func advance(s string, pos, limit, n int) int {
+	for ; n > 0 && pos < limit; n-- {
+		_, size := utf8.DecodeRuneInString(s[pos:limit])
+		pos += size
+	}
+	return pos
+}
+
+func skipBlanks(s string, pos, limit int) int {
+	for pos < limit && isBlank(s[pos]) {
+		pos++
+	}
+	return pos
+}
+
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+// сравнение по числу в начале строки, как sort -n в GNU: пробелы, '-',
+// цифры и десятичная точка; строка без числа равна нулю. Экспоненты, Inf
+// и NaN не разбираются, поэтому порядок транзитивен
+func compareNumeric(a, b string) int {
+	an, ai, af := numericPrefix(a)
+	bn, bi, bf := numericPrefix(b)
+	if an != bn {
+		if an {
+			return -1
+		}
+		return 1
+	}
+	res := len(ai) - len(bi)
+	if res == 0 {
+		res = strings.Compare(ai, bi)
+	}
+	if res == 0 {
+		res = strings.Compare(af, bf)
+	}
+	if an {
+		return -res
+	}
+	return res
+}
+
+// numericPrefix разбирает число в начале строки: знак, целую часть без
+// ведущих нулей и дробную без завершающих; у нуля знака нет
+func numericPrefix(s string) (neg bool, integer, fraction string) {
+	s = strings.TrimLeft(s, " \t")
+	if strings.HasPrefix(s, "-") {
+		neg, s = true, s[1:]
+	}
+	integer = versionPart(s, true)
+	s = s[len(integer):]
+	if strings.HasPrefix(s, ".") {
+		fraction = versionPart(s[1:], true)
+	}
+	integer = strings.TrimLeft(integer, "0")
+	fraction = strings.TrimRight(fraction, "0")
+	if integer == "" && fraction == "" {
+		neg = false
+	}
+	return neg, integer, fraction
+}
+
+// сравнение размеров
+func compareHumanReadable(a, b string) int {
+	parse := func(s string) float64 {
+		s = strings.TrimSpace(s)
+		if len(s) == 0 {
+			return 0
+		}
+		unit := s[len(s)-1]
+		mult := 1.0
+		switch unit {
+		case 'K', 'k':
+			mult = 1e3
+		case 'M', 'm':
+			mult = 1e6
+		case 'G', 'g':
+			mult = 1e9
+		case 'T', 't':
+			mult = 1e12
+		default:
+			unit = 0
+		}
+		if unit != 0 {
+			s = s[:len(s)-1]
+		}
+		num, _ := strconv.ParseFloat(s, 64)
+		return num * mult
+	}
+	af := parse(a)
+	bf := parse(b)
+	if af < bf {
+		return -1
+	} else if af > bf {
+		return 1
+	}
+	return 0
+}
+
+var months = map[string]time.Month{
+	"JAN": time.January, "FEB": time.February, "MAR": time.March, "APR": time.April,
+	"MAY": time.May, "JUN": time.June, "JUL": time.July, "AUG": time.August,
+	"SEP": time.September, "OCT": time.October, "NOV": time.November, "DEC": time.December,
+}
+
+// сравнение по названию месяца в первых трех буквах без учета регистра;
+// неизвестные названия равны между собой и идут раньше Jan, как в GNU
+func compareMonth(a, b string) int {
+	return int(month(a)) - int(month(b))
+}
+
+// month возвращает месяц в начале строки или 0, если месяц не определен
+func month(s string) time.Month {
+	s = strings.TrimLeft(s, " \t")
+	return months[strings.ToUpper(s[:min(3, len(s))])]
+}
+
+// compareVersion сравнивает строки с номерами версий: числа внутри текста
+// сравниваются как числа, остальное - посимвольно
+func compareVersion(a, b string) int {
+	for a != "" && b != "" {
+		da, db := isDigit(a[0]), isDigit(b[0])
+		if da != db {
+			// число идет раньше текста
+			if da {
+				return -1
+			}
+			return 1
+		}
+		pa, pb := versionPart(a, da), versionPart(b, db)
+		var c int
+		if da {
+			na, nb := strings.TrimLeft(pa, "0"), strings.TrimLeft(pb, "0")
+			if c = len(na) - len(nb); c == 0 {
+				c = strings.Compare(na, nb)
+			}
+		} else {
+			c = strings.Compare(pa, pb)
+		}
+		if c != 0 {
+			return c
+		}
+		a, b = a[len(pa):], b[len(pb):]
+	}
+	return len(a) - len(b)
+}
+
+// начало строки из одних цифр или одних не цифр
+func versionPart(s string, digits bool) string {
+	i := 0
+	for i < len(s) && isDigit(s[i]) == digits {
+		i++
+	}
+	return s[:i]
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,235 @@
+package linesort
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// mustKey разбирает описание ключа -k для тестов
+func mustKey(spec string) Key {
+	k, err := ParseKey(spec)
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+func TestSortKeys(t *testing.T) {
+	tests := []struct {
+		name   string
+		keys   []string
+		sep    string
+		stable bool
+		input  []string
+		expect []string
+	}{
+		{
+			// сначала по третьему столбцу по убыванию, затем по первому
+			name:   "-k3,3nr -k1,1",
+			keys:   []string{"3,3nr", "1,1"},
+			input:  []string{"c\tx\t2", "a\tx\t10", "b\tx\t2"},
+			expect: []string{"a\tx\t10", "b\tx\t2", "c\tx\t2"},
+		},
+		{
+			name:   "-k1.2,1.3 (character offsets)",
+			keys:   []string{"1.2,1.3"},
+			input:  []string{"xbz", "ya", "zaa"},
+			expect: []string{"ya", "zaa", "xbz"},
+		},
+		{
+			name:   "-k2b,2 (skip leading blanks)",
+			keys:   []string{"2b,2"},
+			input:  []string{"a\t  2", "b\t1"},
+			expect: []string{"b\t1", "a\t  2"},
+		},
+		{
+			name:   "-k2,2 without b",
+			keys:   []string{"2,2"},
+			input:  []string{"b\t1", "a\t  2"},
+			expect: []string{"a\t  2", "b\t1"},
+		},
+		{
+			name:   "-k1f (fold case)",
+			keys:   []string{"1f"},
+			input:  []string{"b", "a", "A"},
+			expect: []string{"A", "a", "b"},
+		},
+		{
+			name:   "-k1V (version sort)",
+			keys:   []string{"1V"},
+			input:  []string{"v1.10", "v1.2", "v1.9", "v1"},
+			expect: []string{"v1", "v1.2", "v1.9", "v1.10"},
+		},
+		{
+			name:   "-k2M -k1,1r",
+			keys:   []string{"2M", "1,1r"},
+			input:  []string{"x\tMar", "y\tJan", "z\tMar"},
+			expect: []string{"y\tJan", "z\tMar", "x\tMar"},
+		},
+		{
+			name:   "-k2h",
+			keys:   []string{"2h"},
+			input:  []string{"a\t2G", "b\t10K", "c\t1M"},
+			expect: []string{"b\t10K", "c\t1M", "a\t2G"},
+		},
+		{
+			// равные ключи сравниваются по всей строке
+			name:   "last resort",
+			keys:   []string{"1,1"},
+			input:  []string{"b\t2", "a\t1", "a\t0"},
+			expect: []string{"a\t0", "a\t1", "b\t2"},
+		},
+		{
+			name:   "-s (no last resort)",
+			keys:   []string{"1,1"},
+			stable: true,
+			input:  []string{"b\t2", "a\t1", "a\t0"},
+			expect: []string{"a\t1", "a\t0", "b\t2"},
+		},
+		{
+			name:   "-t: -k3,3n (passwd)",
+			keys:   []string{"3,3n"},
+			sep:    ":",
+			input:  []string{"root:x:0:0", "bob:x:1000:1000", "daemon:x:1:1"},
+			expect: []string{"root:x:0:0", "daemon:x:1:1", "bob:x:1000:1000"},
+		},
+		{
+			name:   "-t, -k2,2 (csv)",
+			keys:   []string{"2,2"},
+			sep:    ",",
+			input:  []string{"a,3,x", "b,1,z", "c,2,y"},
+			expect: []string{"b,1,z", "c,2,y", "a,3,x"},
+		},
+		{
+			name:   "-t, empty fields",
+			keys:   []string{"3,3"},
+			sep:    ",",
+			input:  []string{"a,,b", "b,c,a"},
+			expect: []string{"b,c,a", "a,,b"},
+		},
+		{
+			// выровненные пробелами столбцы
+			name:   "blank separated -k2,2n",
+			keys:   []string{"2,2n"},
+			input:  []string{"x    10", "y     2", "zz    5"},
+			expect: []string{"y     2", "zz    5", "x    10"},
+		},
+		{
+			// пробелы перед полем относятся к нему
+			name:   "blank separated -k2,2",
+			keys:   []string{"2,2"},
+			input:  []string{"a b", "a  c"},
+			expect: []string{"a  c", "a b"},
+		},
+		{
+			name:   "blank separated -k2b,2",
+			keys:   []string{"2b,2"},
+			input:  []string{"a  c", "a b"},
+			expect: []string{"a b", "a  c"},
+		},
+		{
+			name:   "missing field",
+			keys:   []string{"3,3n"},
+			input:  []string{"a\tb\t1", "a"},
+			expect: []string{"a", "a\tb\t1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{Separator: tt.sep, Stable: tt.stable}
+			for _, spec := range tt.keys {
+				opts.Keys = append(opts.Keys, mustKey(spec))
+			}
+
+			lines := SortLines(append([]string(nil), tt.input...), opts)
+			if !reflect.DeepEqual(lines, tt.expect) {
+				t.Errorf("got %q, want %q", lines, tt.expect)
+			}
+		})
+	}
+}
+
+func TestComparator(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   Options
+		a, b   string
+		expect int
+	}{
+		{name: "whole line", a: "a", b: "b", expect: -1},
+		{name: "-r", opts: Options{Order: Order{Reverse: true}}, a: "a", b: "b", expect: 1},
+		{name: "-n equal, last resort", opts: Options{Order: Order{Numeric: true}}, a: "01", b: "1", expect: -1},
+		{name: "-n equal, -s", opts: Options{Order: Order{Numeric: true}, Stable: true}, a: "01", b: "1", expect: 0},
+		{name: "-n equal, -u", opts: Options{Order: Order{Numeric: true}, Unique: true}, a: "01", b: "1", expect: 0},
+		{name: "-n -r last resort reversed", opts: Options{Order: Order{Numeric: true, Reverse: true}}, a: "01", b: "1", expect: 1},
+		// ключ с модификатором не берет -n из Options
+		{name: "key modifiers replace global", opts: Options{Order: Order{Numeric: true}, Keys: []Key{mustKey("1b")}},
+			a: "10", b: "9", expect: -1},
+		{name: "key without modifiers inherits global", opts: Options{Order: Order{Numeric: true}, Keys: []Key{mustKey("1")}},
+			a: "10", b: "9", expect: 1},
+		// -n разбирает только число в начале строки, без экспоненты и Inf
+		{name: "-n exponent is not parsed", opts: Options{Order: Order{Numeric: true}}, a: "1e3", b: "2", expect: -1},
+		{name: "-n inf is zero", opts: Options{Order: Order{Numeric: true}, Stable: true}, a: "inf", b: "0", expect: 0},
+		{name: "-n no number is zero", opts: Options{Order: Order{Numeric: true}}, a: "abc", b: "-1", expect: 1},
+		{name: "-n minus zero", opts: Options{Order: Order{Numeric: true}, Stable: true}, a: "-0.0", b: "0", expect: 0},
+		{name: "-n fraction", opts: Options{Order: Order{Numeric: true}}, a: "-1.5", b: "-1.25", expect: -1},
+		{name: "-n prefix", opts: Options{Order: Order{Numeric: true}}, a: " 12abc", b: "9.99x", expect: 1},
+		{name: "-M unknown before Jan", opts: Options{Order: Order{Month: true}}, a: "foo", b: "jan", expect: -1},
+		{name: "-M unknown are equal", opts: Options{Order: Order{Month: true}, Stable: true}, a: "foo", b: "bar", expect: 0},
+		{name: "-M case and blanks", opts: Options{Order: Order{Month: true}}, a: " DECEMBER", b: "nov", expect: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := NewComparator(tt.opts).Compare(tt.a, tt.b)
+			if (res > 0) != (tt.expect > 0) || (res < 0) != (tt.expect < 0) {
+				t.Errorf("Compare(%q, %q) = %d, want sign of %d", tt.a, tt.b, res, tt.expect)
+			}
+		})
+	}
+}
+
+func TestCompareTransitive(t *testing.T) {
+	// сортировка, слияние частей и параллельное слияние дают одно и то же,
+	// только если сравнение транзитивно
+	values := []string{"", "0", "-0", "00", "1", "01", "1.0", "1.5", "-1", "-1.5", ".5", "-.5",
+		"10", "9", "1e3", "inf", "-inf", "NaN", "abc", "-", ".", "  2", "2x", "Jan", "jan", "FEB",
+		"Dec", "december", "foo", "bar", " mar", "Ja"}
+	orders := []Order{{Numeric: true}, {Month: true}, {Numeric: true, Reverse: true}}
+
+	for _, order := range orders {
+		c := NewComparator(Options{Order: order, Stable: true})
+		for _, a := range values {
+			for _, b := range values {
+				ab := c.Compare(a, b)
+				if ba := c.Compare(b, a); (ab < 0) != (ba > 0) || (ab == 0) != (ba == 0) {
+					t.Errorf("%+v: Compare(%q, %q) = %d, reverse %d", order, a, b, ab, ba)
+				}
+				for _, x := range values {
+					if ab <= 0 && c.Compare(b, x) <= 0 && c.Compare(a, x) > 0 {
+						t.Errorf("%+v: %q <= %q <= %q, but %q > %q", order, a, b, x, a, x)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestComparatorConcurrent(t *testing.T) {
+	// один Comparator из нескольких горутин; гонки ловит go test -race
+	c := NewComparator(Options{Order: Order{Numeric: true}, Keys: []Key{mustKey("2,2r"), mustKey("1,1n")}})
+	lines := randomLines(1000, 10)
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 1; i < len(lines); i++ {
+				c.Compare(lines[i-1], lines[i])
+			}
+		}()
+	}
+	wg.Wait()
+}
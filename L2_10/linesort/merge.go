@@ -0,0 +1,109 @@
+package linesort
+
+import (
+	"bufio"
+	"container/heap"
+	"io"
+	"strings"
+)
+
+// Merge сливает уже отсортированные по opts потоки в w, как sort -m. При
+// равенстве строк раньше идет строка из потока, указанного раньше.
+func Merge(w io.Writer, opts Options, inputs ...io.Reader) error {
+	scanners := make([]*bufio.Scanner, len(inputs))
+	for i, r := range inputs {
+		scanners[i] = newLineScanner(r)
+	}
+	bw := bufio.NewWriter(w)
+	if err := merge(bw, NewComparator(opts), opts, scanners); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// сканер строк длиной до 1MB
+func newLineScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	buf := make([]byte, 0, 64*1024) // Буфер 64KB
+	scanner.Buffer(buf, 1024*1024)
+	return scanner
+}
+
+// readLine возвращает строку с учетом IgnoreTrailingBlanks
+func readLine(scanner *bufio.Scanner, opts Options) string {
+	line := scanner.Text()
+	if opts.IgnoreTrailingBlanks {
+		line = strings.TrimRight(line, " \t")
+	}
+	return line
+}
+
+// lineSource - текущая строка одного сливаемого потока
+type lineSource struct {
+	index   int // номер потока; при равенстве строк раньше идет меньший
+	line    string
+	scanner *bufio.Scanner
+}
+
+// mergeHeap - куча потоков по текущей строке
+type mergeHeap struct {
+	c       *Comparator
+	sources []*lineSource
+}
+
+func (h *mergeHeap) Len() int { return len(h.sources) }
+func (h *mergeHeap) Less(i, j int) bool {
+	if c := h.c.Compare(h.sources[i].line, h.sources[j].line); c != 0 {
+		return c < 0
+	}
+	return h.sources[i].index < h.sources[j].index
+}
+func (h *mergeHeap) Swap(i, j int) { h.sources[i], h.sources[j] = h.sources[j], h.sources[i] }
+func (h *mergeHeap) Push(x any)    { h.sources = append(h.sources, x.(*lineSource)) }
+func (h *mergeHeap) Pop() any {
+	old := h.sources
+	src := old[len(old)-1]
+	h.sources = old[:len(old)-1]
+	return src
+}
+
+// merge сливает отсортированные потоки в w; с Unique из равных строк
+// остается первая
+func merge(w *bufio.Writer, c *Comparator, opts Options, scanners []*bufio.Scanner) error {
+	h := &mergeHeap{c: c}
+	for i, scanner := range scanners {
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return err
+			}
+			continue
+		}
+		h.sources = append(h.sources, &lineSource{index: i, line: readLine(scanner, opts), scanner: scanner})
+	}
+	heap.Init(h)
+
+	var last string
+	written := false
+	for h.Len() > 0 {
+		src := h.sources[0]
+		if !opts.Unique || !written || c.Compare(last, src.line) != 0 {
+			if _, err := w.WriteString(src.line); err != nil {
+				return err
+			}
+			if err := w.WriteByte('\n'); err != nil {
+				return err
+			}
+			last, written = src.line, true
+		}
+		if src.scanner.Scan() {
+			src.line = readLine(src.scanner, opts)
+			heap.Fix(h, 0)
+			continue
+		}
+		if err := src.scanner.Err(); err != nil {
+			return err
+		}
+		heap.Pop(h)
+	}
+	return nil
+}
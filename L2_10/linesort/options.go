@@ -0,0 +1,171 @@
+// Package linesort сортирует строки текста по правилам, близким к GNU sort:
+// ключи -k с модификаторами, разделитель полей, удаление повторов, внешняя
+// сортировка через временные файлы и параллельная сортировка частей.
+package linesort
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultBufferSize - размер буфера, если Options.BufferSize не задан
+const DefaultBufferSize = 256 << 20
+
+// Order - способ сравнения строки или ключа.
+type Order struct {
+	Numeric      bool // как десятичные числа в начале строки (-n)
+	HumanNumeric bool // как размеры с суффиксами K, M, G, T (-h)
+	Month        bool // как названия месяцев (-M)
+	Version      bool // как номера версий (-V)
+	Fold         bool // без учета регистра (-f)
+	Reverse      bool // в обратном порядке (-r)
+}
+
+// Key - ключ сортировки POS1[,POS2]. Поля и символы нумеруются с 1;
+// EndField 0 означает конец строки, EndChar 0 - конец поля.
+type Key struct {
+	StartField, StartChar int
+	EndField, EndChar     int
+
+	SkipStartBlanks bool // b в POS1: пропустить пробелы в начале поля
+	SkipEndBlanks   bool // b в POS2
+
+	// Order - порядок ключа; nil означает порядок из Options, как у ключа
+	// без модификаторов в GNU sort
+	Order *Order
+}
+
+// Options - параметры сортировки. Нулевое значение сортирует строки целиком
+// побайтно в одном потоке.
+type Options struct {
+	Order // порядок всей строки и ключей без модификаторов
+
+	Keys      []Key  // ключи в порядке сравнения
+	Separator string // разделитель полей; пусто - поля разделяются пробелами
+
+	Unique               bool // из равных строк остается первая (-u)
+	Stable               bool // без сравнения строк целиком при равных ключах (-s)
+	IgnoreTrailingBlanks bool // удалять пробелы в конце строк (-b)
+
+	BufferSize int64  // размер части в байтах при внешней сортировке
+	TempDir    string // каталог временных файлов; пусто - os.TempDir
+	Compress   bool   // сжимать временные файлы gzip
+	Parallel   int    // сколько частей сортируется одновременно
+}
+
+func (o Options) bufferSize() int64 {
+	if o.BufferSize > 0 {
+		return o.BufferSize
+	}
+	return DefaultBufferSize
+}
+
+// ParseKey разбирает описание ключа как GNU sort: "2,2n", "1.3b,1.5".
+func ParseKey(spec string) (Key, error) {
+	var k Key
+	pos1, pos2, hasEnd := strings.Cut(spec, ",")
+
+	field, char, opts, err := parsePosition(pos1)
+	if err != nil {
+		return k, fmt.Errorf("invalid key %q: %w", spec, err)
+	}
+	if char == 0 {
+		if strings.Contains(pos1, ".") {
+			return k, fmt.Errorf("invalid key %q: character offset is zero", spec)
+		}
+		char = 1
+	}
+	k.StartField, k.StartChar = field, char
+	if err := k.setOptions(opts, &k.SkipStartBlanks); err != nil {
+		return k, fmt.Errorf("invalid key %q: %w", spec, err)
+	}
+
+	if hasEnd {
+		field, char, opts, err := parsePosition(pos2)
+		if err != nil {
+			return k, fmt.Errorf("invalid key %q: %w", spec, err)
+		}
+		k.EndField, k.EndChar = field, char
+		if err := k.setOptions(opts, &k.SkipEndBlanks); err != nil {
+			return k, fmt.Errorf("invalid key %q: %w", spec, err)
+		}
+	}
+	return k, nil
+}
+
+// разбор F[.C][OPTS]
+func parsePosition(pos string) (field, char int, opts string, err error) {
+	num := strings.IndexFunc(pos, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if num < 0 {
+		num = len(pos)
+	}
+	fieldStr, charStr, hasChar := strings.Cut(pos[:num], ".")
+	if field, err = strconv.Atoi(fieldStr); err != nil || field < 1 {
+		return 0, 0, "", fmt.Errorf("field number %q must be positive", fieldStr)
+	}
+	if hasChar {
+		if char, err = strconv.Atoi(charStr); err != nil || char < 0 {
+			return 0, 0, "", fmt.Errorf("invalid character offset %q", charStr)
+		}
+	}
+	return field, char, pos[num:], nil
+}
+
+// setOptions применяет модификаторы ключа; b относится к своей позиции.
+// Любой модификатор отключает порядок из Options.
+func (k *Key) setOptions(opts string, skipBlanks *bool) error {
+	for _, o := range opts {
+		if k.Order == nil {
+			k.Order = &Order{}
+		}
+		switch o {
+		case 'b':
+			*skipBlanks = true
+		case 'f':
+			k.Order.Fold = true
+		case 'h':
+			k.Order.HumanNumeric = true
+		case 'M':
+			k.Order.Month = true
+		case 'n':
+			k.Order.Numeric = true
+		case 'r':
+			k.Order.Reverse = true
+		case 'V':
+			k.Order.Version = true
+		default:
+			return fmt.Errorf("unknown option %q", o)
+		}
+	}
+	return nil
+}
+
+// ParseSize разбирает размер буфера как GNU sort: число без суффикса - в
+// килобайтах, b - в байтах, K, M, G, T - степени 1024. Другие суффиксы,
+// в том числе процент от памяти, не поддерживаются.
+func ParseSize(s string) (int64, error) {
+	digits, mult := s, int64(1<<10)
+	if n := len(s); n > 0 && !isDigit(s[n-1]) {
+		switch s[n-1] {
+		case 'b':
+			mult = 1
+		case 'K', 'k':
+			mult = 1 << 10
+		case 'M', 'm':
+			mult = 1 << 20
+		case 'G', 'g':
+			mult = 1 << 30
+		case 'T', 't':
+			mult = 1 << 40
+		default:
+			return 0, fmt.Errorf("invalid buffer size %q: unknown suffix %q", s, s[n-1:])
+		}
+		digits = s[:n-1]
+	}
+	size, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || size <= 0 || size > (1<<62)/mult {
+		return 0, fmt.Errorf("invalid buffer size %q", s)
+	}
+	return size * mult, nil
+}
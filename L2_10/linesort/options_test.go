@@ -0,0 +1,64 @@
+package linesort
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		spec   string
+		expect Key
+		err    bool
+	}{
+		{spec: "2", expect: Key{StartField: 2, StartChar: 1}},
+		{spec: "2,3", expect: Key{StartField: 2, StartChar: 1, EndField: 3}},
+		{spec: "2.3b,2.5nr", expect: Key{StartField: 2, StartChar: 3, EndField: 2, EndChar: 5,
+			SkipStartBlanks: true, Order: &Order{Numeric: true, Reverse: true}}},
+		{spec: "1b", expect: Key{StartField: 1, StartChar: 1, SkipStartBlanks: true, Order: &Order{}}},
+		{spec: "1,1.0", expect: Key{StartField: 1, StartChar: 1, EndField: 1}},
+		{spec: "0", err: true},
+		{spec: "1.0", err: true},
+		{spec: "x", err: true},
+		{spec: "1q", err: true},
+		{spec: "1,", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			k, err := ParseKey(tt.spec)
+			if (err != nil) != tt.err || (err == nil && !reflect.DeepEqual(k, tt.expect)) {
+				t.Errorf("got %+v, %v, want %+v", k, err, tt.expect)
+			}
+		})
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input  string
+		expect int64
+		err    bool
+	}{
+		{input: "10", expect: 10 << 10},
+		{input: "100b", expect: 100},
+		{input: "64K", expect: 64 << 10},
+		{input: "2M", expect: 2 << 20},
+		{input: "1G", expect: 1 << 30},
+		{input: "0", err: true},
+		{input: "M", err: true},
+		{input: "-5K", err: true},
+		{input: "99999999T", err: true},
+		{input: "10x", err: true},
+		{input: "10%", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			size, err := ParseSize(tt.input)
+			if (err != nil) != tt.err || size != tt.expect {
+				t.Errorf("got %d, %v, want %d", size, err, tt.expect)
+			}
+		})
+	}
+}
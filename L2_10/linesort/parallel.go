@@ -0,0 +1,88 @@
+package linesort
+
+import (
+	"sort"
+	"sync"
+)
+
+// меньшие части сортируются в одном потоке: накладные расходы на горутины
+// превышают выигрыш
+const minParallelChunk = 4096
+
+// sortStable сортирует строки с сохранением порядка равных, как
+// sort.SliceStable. При workers > 1 ввод делится на соседние части, которые
+// сортируются одновременно и затем попарно сливаются; при равенстве берется
+// строка из левой части, поэтому результат совпадает с последовательной
+// сортировкой.
+func sortStable(lines []string, c *Comparator, workers int) {
+	workers = min(workers, len(lines)/minParallelChunk)
+	if workers <= 1 {
+		sort.SliceStable(lines, func(i, j int) bool {
+			return c.Compare(lines[i], lines[j]) < 0
+		})
+		return
+	}
+
+	// границы частей
+	bounds := make([]int, workers+1)
+	for i := range bounds {
+		bounds[i] = len(lines) * i / workers
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		part := lines[bounds[i]:bounds[i+1]]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sort.SliceStable(part, func(i, j int) bool {
+				return c.Compare(part[i], part[j]) < 0
+			})
+		}()
+	}
+	wg.Wait()
+
+	// попарное слияние соседних частей, пока не останется одна
+	src, dst := lines, make([]string, len(lines))
+	for len(bounds) > 2 {
+		next := []int{0}
+		for i := 0; i+1 < len(bounds); i += 2 {
+			if i+2 == len(bounds) {
+				// нечетная часть переносится как есть
+				copy(dst[bounds[i]:bounds[i+1]], src[bounds[i]:bounds[i+1]])
+				next = append(next, bounds[i+1])
+				continue
+			}
+			lo, mid, hi := bounds[i], bounds[i+1], bounds[i+2]
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				mergeStable(dst[lo:hi], src[lo:mid], src[mid:hi], c)
+			}()
+			next = append(next, hi)
+		}
+		wg.Wait()
+		src, dst, bounds = dst, src, next
+	}
+	if &src[0] != &lines[0] {
+		copy(lines, src)
+	}
+}
+
+// mergeStable сливает отсортированные a и b в dst; при равенстве первой
+// идет строка из a
+func mergeStable(dst, a, b []string, c *Comparator) {
+	i, j, k := 0, 0, 0
+	for i < len(a) && j < len(b) {
+		if c.Compare(b[j], a[i]) < 0 {
+			dst[k] = b[j]
+			j++
+		} else {
+			dst[k] = a[i]
+			i++
+		}
+		k++
+	}
+	k += copy(dst[k:], a[i:])
+	copy(dst[k:], b[j:])
+}
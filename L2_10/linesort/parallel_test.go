@@ -0,0 +1,70 @@
+package linesort
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// строки с большим числом равных ключей в первом столбце
+func randomLines(n int, distinct int) []string {
+	rnd := rand.New(rand.NewSource(1))
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("%d\t%d", rnd.Intn(distinct), i)
+	}
+	return lines
+}
+
+func TestSortStableParallel(t *testing.T) {
+	// Stable: равные ключи остаются в порядке ввода
+	firstColumn := []Key{mustKey("1,1")}
+	tests := []struct {
+		name     string
+		lines    int
+		parallel int
+		opts     Options
+	}{
+		{name: "меньше порога", lines: minParallelChunk, parallel: 8, opts: Options{Keys: firstColumn, Stable: true}},
+		{name: "2 потока", lines: 3 * minParallelChunk, parallel: 2, opts: Options{Keys: firstColumn, Stable: true}},
+		{name: "3 потока", lines: 5 * minParallelChunk, parallel: 3,
+			opts: Options{Order: Order{Numeric: true}, Keys: firstColumn, Stable: true}},
+		{name: "7 потоков -r", lines: 10*minParallelChunk + 3, parallel: 7,
+			opts: Options{Order: Order{Numeric: true, Reverse: true}, Keys: firstColumn, Stable: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewComparator(tt.opts)
+			input := randomLines(tt.lines, 100)
+			expect := append([]string(nil), input...)
+			sort.SliceStable(expect, func(i, j int) bool {
+				return c.Compare(expect[i], expect[j]) < 0
+			})
+
+			got := append([]string(nil), input...)
+			sortStable(got, c, tt.parallel)
+			if !reflect.DeepEqual(got, expect) {
+				t.Errorf("parallel sort differs from sort.SliceStable")
+			}
+		})
+	}
+}
+
+func BenchmarkSortLines(b *testing.B) {
+	opts := Options{Order: Order{Numeric: true}, Keys: []Key{mustKey("1,1")}}
+	input := randomLines(1<<18, 1<<14)
+	lines := make([]string, len(input))
+
+	for _, n := range []int{1, 2, 4, 8, 16} {
+		b.Run(fmt.Sprintf("parallel=%d", n), func(b *testing.B) {
+			opts.Parallel = n
+			for i := 0; i < b.N; i++ {
+				copy(lines, input)
+				SortLines(lines, opts)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package linesort
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	lineOverhead = 16 // заголовок строки в срезе, учитывается в BufferSize
+	mergeFanIn   = 64 // сколько частей сливается за один проход
+)
+
+// ErrCleanedUp возвращается, если временные файлы удалены во время сортировки.
+var ErrCleanedUp = errors.New("sort interrupted: temporary files removed")
+
+// SortLines сортирует строки в памяти с сохранением порядка равных и
+// возвращает результат; с Unique повторы удаляются.
+func SortLines(lines []string, opts Options) []string {
+	c := NewComparator(opts)
+	sortStable(lines, c, opts.Parallel)
+	if opts.Unique {
+		lines = removeDuplicates(lines, c)
+	}
+	return lines
+}
+
+// Удаление дубликатов из отсортированного списка
+func removeDuplicates(lines []string, c *Comparator) []string {
+	if len(lines) == 0 {
+		return lines
+	}
+	j := 0
+	for i := 1; i < len(lines); i++ {
+		if c.Compare(lines[j], lines[i]) != 0 {
+			j++
+			lines[j] = lines[i]
+		}
+	}
+	return lines[:j+1]
+}
+
+// IsSorted проверяет, что строки из r отсортированы по opts; с Unique
+// равные строки тоже считаются нарушением порядка, как в sort -c -u.
+func IsSorted(r io.Reader, opts Options) (bool, error) {
+	c := NewComparator(opts)
+	scanner := newLineScanner(r)
+	var prev string
+	for first := true; scanner.Scan(); first = false {
+		line := readLine(scanner, opts)
+		if !first {
+			if res := c.Compare(prev, line); res > 0 || (opts.Unique && res == 0) {
+				return false, nil
+			}
+		}
+		prev = line
+	}
+	if err := scanner.Err(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Sort сортирует строки из r и пишет их в w; временные файлы удаляются
+// перед возвратом.
+func Sort(w io.Writer, r io.Reader, opts Options) error {
+	s := NewSorter(opts)
+	err := s.Sort(w, r)
+	if cleanupErr := s.Cleanup(); err == nil {
+		err = cleanupErr
+	}
+	return err
+}
+
+// Sorter сортирует ввод частями не больше BufferSize: отсортированные части
+// сбрасываются во временные файлы и затем сливаются через кучу. Cleanup
+// можно вызвать из другой горутины, например из обработчика сигнала.
+type Sorter struct {
+	opts Options
+	c    *Comparator
+
+	mu      sync.Mutex
+	dir     string // каталог для частей, создается при первом сбросе
+	removed bool
+	runs    []string
+}
+
+// NewSorter создает сортировку с параметрами opts для одного ввода.
+func NewSorter(opts Options) *Sorter {
+	return &Sorter{opts: opts, c: NewComparator(opts)}
+}
+
+// Sort сортирует строки из r и пишет результат в w. Если ввод поместился в
+// буфер, временные файлы не создаются. После Sort нужно вызвать Cleanup.
+func (s *Sorter) Sort(w io.Writer, r io.Reader) error {
+	var lines []string
+	var size int64
+	scanner := newLineScanner(r)
+	for scanner.Scan() {
+		line := readLine(scanner, s.opts)
+		lines = append(lines, line)
+		size += int64(len(line)) + lineOverhead
+		if size >= s.opts.bufferSize() {
+			if err := s.spill(lines); err != nil {
+				return err
+			}
+			lines, size = nil, 0
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(w)
+	if len(s.runs) == 0 {
+		if err := writeLines(out, SortLines(lines, s.opts)); err != nil {
+			return err
+		}
+		return out.Flush()
+	}
+	if len(lines) > 0 {
+		if err := s.spill(lines); err != nil {
+			return err
+		}
+	}
+
+	// сливаем соседние части, пока их не станет не больше mergeFanIn;
+	// порядок частей сохраняется, чтобы равные строки шли в порядке ввода
+	for len(s.runs) > mergeFanIn {
+		var next []string
+		for i := 0; i < len(s.runs); i += mergeFanIn {
+			batch := s.runs[i:min(i+mergeFanIn, len(s.runs))]
+			path, err := s.writeRun(func(w *bufio.Writer) error { return s.mergeRuns(w, batch) })
+			if err != nil {
+				return err
+			}
+			for _, p := range batch {
+				os.Remove(p)
+			}
+			next = append(next, path)
+		}
+		s.runs = next
+	}
+	if err := s.mergeRuns(out, s.runs); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// spill сортирует часть и сбрасывает ее во временный файл
+func (s *Sorter) spill(lines []string) error {
+	lines = SortLines(lines, s.opts)
+	path, err := s.writeRun(func(w *bufio.Writer) error { return writeLines(w, lines) })
+	if err != nil {
+		return err
+	}
+	s.runs = append(s.runs, path)
+	return nil
+}
+
+// writeRun создает временный файл части и заполняет его функцией fill
+func (s *Sorter) writeRun(fill func(*bufio.Writer) error) (string, error) {
+	f, err := s.createRun()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var gz *gzip.Writer
+	w := bufio.NewWriter(f)
+	if s.opts.Compress {
+		gz, _ = gzip.NewWriterLevel(f, gzip.BestSpeed)
+		w = bufio.NewWriter(gz)
+	}
+	if err := fill(w); err != nil {
+		return "", err
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return "", err
+		}
+	}
+	return f.Name(), f.Close()
+}
+
+func (s *Sorter) createRun() (*os.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.removed {
+		return nil, ErrCleanedUp
+	}
+	if s.dir == "" {
+		dir, err := os.MkdirTemp(s.opts.TempDir, "sort-")
+		if err != nil {
+			return nil, err
+		}
+		s.dir = dir
+	}
+	return os.CreateTemp(s.dir, "run-*")
+}
+
+// mergeRuns сливает временные файлы частей в w
+func (s *Sorter) mergeRuns(w *bufio.Writer, paths []string) error {
+	var closers []io.Closer
+	defer func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i].Close()
+		}
+	}()
+
+	scanners := make([]*bufio.Scanner, len(paths))
+	for i, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		closers = append(closers, f)
+		var r io.Reader = bufio.NewReader(f)
+		if s.opts.Compress {
+			gz, err := gzip.NewReader(r)
+			if err != nil {
+				return fmt.Errorf("%s: %w", filepath.Base(path), err)
+			}
+			closers = append(closers, gz)
+			r = gz
+		}
+		scanners[i] = newLineScanner(r)
+	}
+	return merge(w, s.c, s.opts, scanners)
+}
+
+// Cleanup удаляет все временные файлы; после него Sort возвращает
+// ErrCleanedUp при попытке создать новую часть. Безопасно вызывать повторно
+// и одновременно с Sort.
+func (s *Sorter) Cleanup() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removed = true
+	if s.dir == "" {
+		return nil
+	}
+	return os.RemoveAll(s.dir)
+}
+
+// запись строк с переводом строки после каждой
+func writeLines(w *bufio.Writer, lines []string) error {
+	for _, line := range lines {
+		if _, err := w.WriteString(line); err != nil {
+			return err
+		}
+		if err := w.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	return nil
+}
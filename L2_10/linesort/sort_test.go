@@ -0,0 +1,127 @@
+package linesort
+
+import (
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExternalSort(t *testing.T) {
+	// строки с повторяющимися ключами, чтобы проверить порядок равных
+	input := randomLines(1000, 50)
+	numeric := Order{Numeric: true}
+	reverse := Order{Numeric: true, Reverse: true}
+	firstColumn := []Key{mustKey("1,1")}
+
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "в памяти", opts: Options{}},
+		{
+			// больше mergeFanIn частей - слияние в несколько проходов
+			name: "по строке в части",
+			opts: Options{Order: numeric, Keys: firstColumn, BufferSize: 1},
+		},
+		{name: "-k1nr с сжатием", opts: Options{Order: reverse, Keys: firstColumn, BufferSize: 512, Compress: true}},
+		{name: "-k1nu", opts: Options{Order: numeric, Keys: firstColumn, Unique: true, BufferSize: 512}},
+		{name: "-k1nru", opts: Options{Order: reverse, Keys: firstColumn, Unique: true, BufferSize: 300}},
+		{name: "-k1n -s параллельно", opts: Options{Order: numeric, Keys: firstColumn, Stable: true, BufferSize: 4096, Parallel: 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.TempDir = t.TempDir()
+			expect := SortLines(append([]string(nil), input...), tt.opts)
+
+			var out strings.Builder
+			err := Sort(&out, strings.NewReader(strings.Join(input, "\n")+"\n"), tt.opts)
+			if err != nil {
+				t.Fatalf("sort error: %v", err)
+			}
+			got := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+			if !reflect.DeepEqual(got, expect) {
+				t.Errorf("external sort differs from in-memory sort")
+			}
+			if entries, _ := os.ReadDir(tt.opts.TempDir); len(entries) != 0 {
+				t.Errorf("temporary files left: %v", entries)
+			}
+		})
+	}
+}
+
+func TestSorterCleanup(t *testing.T) {
+	// после Cleanup, например по сигналу, новые части не создаются
+	s := NewSorter(Options{BufferSize: 1, TempDir: t.TempDir()})
+	if err := s.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := s.Sort(&out, strings.NewReader("b\na\n")); !errors.Is(err, ErrCleanedUp) {
+		t.Errorf("expected ErrCleanedUp, got %v", err)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   Options
+		inputs []string
+		expect string
+	}{
+		{name: "пустые", inputs: []string{"", ""}, expect: ""},
+		{name: "по строкам", inputs: []string{"a\nc\ne\n", "b\nd\n", "f"}, expect: "a\nb\nc\nd\ne\nf\n"},
+		{
+			// при равных ключах раньше идет строка из первого потока
+			name:   "-s равные ключи",
+			opts:   Options{Keys: []Key{mustKey("1,1")}, Stable: true},
+			inputs: []string{"a 2\nb 1\n", "a 1\n"},
+			expect: "a 2\na 1\nb 1\n",
+		},
+		{name: "-nr", opts: Options{Order: Order{Numeric: true, Reverse: true}}, inputs: []string{"10\n2\n", "5\n1\n"}, expect: "10\n5\n2\n1\n"},
+		{name: "-u", opts: Options{Unique: true}, inputs: []string{"a\nb\n", "a\nb\nc\n"}, expect: "a\nb\nc\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var inputs []io.Reader
+			for _, in := range tt.inputs {
+				inputs = append(inputs, strings.NewReader(in))
+			}
+			var out strings.Builder
+			if err := Merge(&out, tt.opts, inputs...); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.expect {
+				t.Errorf("got %q, want %q", out.String(), tt.expect)
+			}
+		})
+	}
+}
+
+func TestIsSorted(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   Options
+		input  string
+		expect bool
+	}{
+		{name: "пустой", input: "", expect: true},
+		{name: "отсортирован", input: "a\nb\nb\n", expect: true},
+		{name: "не отсортирован", input: "b\na\n", expect: false},
+		{name: "-u с повтором", opts: Options{Unique: true}, input: "a\nb\nb\n", expect: false},
+		{name: "-k2,2n", opts: Options{Keys: []Key{mustKey("2,2n")}}, input: "x 2\na 10\n", expect: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sorted, err := IsSorted(strings.NewReader(tt.input), tt.opts)
+			if err != nil || sorted != tt.expect {
+				t.Errorf("got %v, %v, want %v", sorted, err, tt.expect)
+			}
+		})
+	}
+}
@@ -3,129 +3,169 @@ package main
 import (
 	"reflect"
 	"testing"
+
+	"WB_L2/L2_10/linesort"
 )
 
 func TestSortFlags(t *testing.T) {
 	tests := []struct {
 		name   string
+		args   []string
 		input  []string
-		flags  func()
 		expect []string
 	}{
 		{
 			//обычные числа
-			name:  "-n (numeric sort)",
-			input: []string{"10", "2", "33"},
-			flags: func() {
-				number, reverse, unique, month, sizeNumber, column = true, false, false, false, false, 0
-			},
+			name:   "-n (numeric sort)",
+			args:   []string{"-n"},
+			input:  []string{"10", "2", "33"},
 			expect: []string{"2", "10", "33"},
 		},
 		{
 			//обратная сортировка
-			name:  "-r (reverse sort)",
-			input: []string{"apple", "banana", "cherry"},
-			flags: func() {
-				number, reverse, unique, month, sizeNumber, column = false, true, false, false, false, 0
-			},
+			name:   "-r (reverse sort)",
+			args:   []string{"-r"},
+			input:  []string{"apple", "banana", "cherry"},
 			expect: []string{"cherry", "banana", "apple"},
 		},
 		{
 			//очистка от дубликатов
-			name:  "-u (unique)",
-			input: []string{"a", "b", "a", "c"},
-			flags: func() {
-				number, reverse, unique, month, sizeNumber, column = false, false, true, false, false, 0
-			},
+			name:   "-u (unique)",
+			args:   []string{"-u"},
+			input:  []string{"a", "b", "a", "c"},
 			expect: []string{"a", "b", "c"},
 		},
 		{
 			//сортировка по дате
-			name:  "-M (month sort)",
-			input: []string{"Feb", "Jan", "Dec"},
-			flags: func() {
-				number, reverse, unique, month, sizeNumber, column = false, false, false, true, false, 0
-			},
+			name:   "-M (month sort)",
+			args:   []string{"-M"},
+			input:  []string{"Feb", "Jan", "Dec"},
 			expect: []string{"Jan", "Feb", "Dec"},
 		},
 		{
 			// Смешанные единицы измерения - должны сортироваться
 			// по числовому эквиваленту
-			name:  "-h (human-readable sort)",
-			input: []string{"1K", "200", "3M"},
-			flags: func() {
-				number, reverse, unique, month, sizeNumber, column = false, false, false, false, true, 0
-			},
+			name:   "-h (human-readable sort)",
+			args:   []string{"-h"},
+			input:  []string{"1K", "200", "3M"},
 			expect: []string{"200", "1K", "3M"},
 		},
 		{
 			// Отсутствующие колонки - строки без достаточного
 			// количества колонок должны считаться пустыми
-			name:  "-k2 (sort by 2nd column)",
-			input: []string{"a	3", "b	1", "c	2"},
-			flags: func() {
-				number, reverse, unique, month, sizeNumber, column = false, false, false, false, false, 2
-			},
+			name:   "-k2 (sort by 2nd column)",
+			args:   []string{"-k", "2"},
+			input:  []string{"a	3", "b	1", "c	2"},
 			expect: []string{"b\t1", "c\t2", "a\t3"},
 		},
 		{
 			// Пустые числовые поля - должны обрабатываться
 			// как нули или пустые значения
-			name:  "-k2nr (numeric reverse by column)",
-			input: []string{"x	10", "y	2", "z	5"},
-			flags: func() {
-				number, reverse, unique, month, sizeNumber, column = true, true, false, false, false, 2
-			},
+			name:   "-k2nr (numeric reverse by column)",
+			args:   []string{"-n", "-r", "-k", "2"},
+			input:  []string{"x	10", "y	2", "z	5"},
 			expect: []string{"x\t10", "z\t5", "y\t2"},
 		},
 		{
 			// Комбинация флагов - проверка комплексного поведения
 			// числовая обратная сортировка по колонке с удалением дублей
-			name:  "-k2nu (unique numeric by column)",
-			input: []string{"a	1", "b	2", "c	1", "d	3"},
-			flags: func() {
-				number, reverse, unique, month, sizeNumber, column = true, false, true, false, false, 2
-			},
+			name:   "-k2nu (unique numeric by column)",
+			args:   []string{"-n", "-u", "-k", "2"},
+			input:  []string{"a	1", "b	2", "c	1", "d	3"},
 			expect: []string{"a\t1", "b\t2", "d\t3"},
 		},
 		{
 			//игнорируем хвостовые пробелы
-			name:  "-b with trailing spaces",
-			input: []string{"a  ", " b", "  c"},
-			flags: func() {
-				ignoreTBlanks = true
-				number, reverse, unique, month, sizeNumber, column = false, false, false, false, false, 0
-			},
+			name:   "-b with trailing spaces",
+			args:   []string{"-b"},
+			input:  []string{"a  ", " b", "  c"},
 			expect: []string{"  c", " b", "a  "},
 		},
+		{
+			// ключ без модификаторов берет -n и -r из общих флагов
+			name:   "-n -r -t, -k2 (global options for key)",
+			args:   []string{"-n", "-r", "-t", ",", "-k", "2"},
+			input:  []string{"a,9", "b,10", "c,2"},
+			expect: []string{"b,10", "a,9", "c,2"},
+		},
+		{
+			// у ключа со своими модификаторами общие флаги не действуют
+			name:   "-n -t, -k2r (key modifiers replace global)",
+			args:   []string{"-n", "-t", ",", "-k", "2r"},
+			input:  []string{"a,9", "b,10", "c,2"},
+			expect: []string{"a,9", "c,2", "b,10"},
+		},
 		{
 			//проверка должна быть в main
-			name:  "-c with sorted input",
-			input: []string{"a", "b", "c"},
-			flags: func() {
-				checkSort = true
-			},
+			name:   "-c with sorted input",
+			args:   []string{"-c"},
+			input:  []string{"a", "b", "c"},
 			expect: []string{"a", "b", "c"}, // Actual check happens in main()
 		},
 		{
 			//проверка без параметров
-			name:  "single line input",
-			input: []string{"single line"},
-			flags: func() {
-				number, reverse, unique, month, sizeNumber, column = false, false, false, false, false, 0 //все false
-			},
+			name:   "single line input",
+			args:   []string{},
+			input:  []string{"single line"},
 			expect: []string{"single line"},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.flags()
+			cfg, err := parseFlags(tt.args)
+			if err != nil {
+				t.Fatalf("parseFlags(%q): %v", tt.args, err)
+			}
 			lines := append([]string(nil), tt.input...)
-			lines = sortStrings(lines)
+			lines = linesort.SortLines(lines, cfg.opts)
 			if !reflect.DeepEqual(lines, tt.expect) {
 				t.Errorf("got %v, want %v", lines, tt.expect)
 			}
 		})
 	}
 }
+
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		err  bool
+		test func(cfg config) bool
+	}{
+		{name: "defaults", args: nil,
+			test: func(cfg config) bool {
+				return cfg.opts.BufferSize == linesort.DefaultBufferSize && cfg.opts.Parallel == 1 && cfg.file == ""
+			}},
+		{name: "keys in order", args: []string{"-k", "3,3nr", "-k", "1,1", "file.txt"},
+			test: func(cfg config) bool {
+				return len(cfg.opts.Keys) == 2 && cfg.opts.Keys[0].StartField == 3 && cfg.opts.Keys[1].StartField == 1 &&
+					cfg.file == "file.txt"
+			}},
+		{name: "external sort", args: []string{"-S", "10M", "-T", "/var/tmp", "-compress", "--parallel=8"},
+			test: func(cfg config) bool {
+				return cfg.opts.BufferSize == 10<<20 && cfg.opts.TempDir == "/var/tmp" && cfg.opts.Compress && cfg.opts.Parallel == 8
+			}},
+		{name: "separator", args: []string{"-t", ":"},
+			test: func(cfg config) bool { return cfg.opts.Separator == ":" }},
+		{name: "long separator", args: []string{"-t", "::"}, err: true},
+		{name: "bad key", args: []string{"-k", "0"}, err: true},
+		{name: "bad size", args: []string{"-S", "x"}, err: true},
+		{name: "no parallel sorts", args: []string{"--parallel=0"}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := parseFlags(tt.args)
+			if tt.err {
+				if err == nil {
+					t.Errorf("expected error for %q", tt.args)
+				}
+				return
+			}
+			if err != nil || !tt.test(cfg) {
+				t.Errorf("parseFlags(%q) = %+v, %v", tt.args, cfg, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// serverStats - статистика одного сервера по журналу
+type serverStats struct {
+	server    string
+	total     int           // всего измерений
+	ok        int           // успешных измерений
+	min, max  float64       // смещение, секунды
+	mean      float64       // среднее смещение
+	jitter    float64       // СКО разностей соседних смещений
+	interval  time.Duration // медианный интервал между измерениями
+	deviation []allanPoint  // девиация Аллана
+}
+
+// allanPoint - девиация Аллана для интервала усреднения tau
+type allanPoint struct {
+	tau  time.Duration
+	adev float64
+}
+
+// чтение журнала JSON Lines
+func readLog(r io.Reader) ([]logRecord, error) {
+	var records []logRecord
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var rec logRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+// девиация Аллана по фазовым данным x (смещениям), снятым с шагом tau0:
+// σ²(τ) = Σ(x[i+2m] - 2x[i+m] + x[i])² / (2τ²(N-2m)), τ = m·tau0
+func allanDeviation(x []float64, m int, tau0 time.Duration) (float64, bool) {
+	n := len(x)
+	if m < 1 || n <= 2*m {
+		return 0, false
+	}
+	tau := float64(m) * tau0.Seconds()
+	var sum float64
+	for i := 0; i+2*m < n; i++ {
+		d := x[i+2*m] - 2*x[i+m] + x[i]
+		sum += d * d
+	}
+	return math.Sqrt(sum / (2 * tau * tau * float64(n-2*m))), true
+}
+
+// анализ журнала: статистика по серверам в порядке имен; для девиации Аллана
+// используются интервалы taus, а если они не заданы - степени двойки от
+// медианного интервала опроса
+func analyze(records []logRecord, taus []time.Duration) []serverStats {
+	byServer := map[string][]logRecord{}
+	for _, r := range records {
+		byServer[r.Server] = append(byServer[r.Server], r)
+	}
+
+	var stats []serverStats
+	for server, list := range byServer {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].Timestamp.Before(list[j].Timestamp) })
+		st := serverStats{server: server, total: len(list)}
+
+		var offsets []float64
+		var times []time.Time
+		for _, r := range list {
+			if r.Error != "" {
+				continue
+			}
+			offsets = append(offsets, r.Offset)
+			times = append(times, r.Timestamp)
+		}
+		st.ok = len(offsets)
+		if st.ok == 0 {
+			stats = append(stats, st)
+			continue
+		}
+
+		st.min, st.max = offsets[0], offsets[0]
+		var sum, sumSq float64
+		for i, x := range offsets {
+			st.min = math.Min(st.min, x)
+			st.max = math.Max(st.max, x)
+			sum += x
+			if i > 0 {
+				d := x - offsets[i-1]
+				sumSq += d * d
+			}
+		}
+		st.mean = sum / float64(st.ok)
+		if st.ok > 1 {
+			st.jitter = math.Sqrt(sumSq / float64(st.ok-1))
+
+			intervals := make([]time.Duration, 0, len(times)-1)
+			for i := 1; i < len(times); i++ {
+				intervals = append(intervals, times[i].Sub(times[i-1]))
+			}
+			sort.Slice(intervals, func(i, j int) bool { return intervals[i] < intervals[j] })
+			st.interval = intervals[len(intervals)/2]
+		}
+
+		// данные считаются снятыми с шагом, равным медианному интервалу
+		if st.interval > 0 {
+			if len(taus) == 0 {
+				for m := 1; st.ok > 2*m; m *= 2 {
+					if adev, ok := allanDeviation(offsets, m, st.interval); ok {
+						st.deviation = append(st.deviation, allanPoint{tau: time.Duration(m) * st.interval, adev: adev})
+					}
+				}
+			}
+			for _, tau := range taus {
+				m := int(math.Round(float64(tau) / float64(st.interval)))
+				if adev, ok := allanDeviation(offsets, m, st.interval); ok {
+					st.deviation = append(st.deviation, allanPoint{tau: time.Duration(m) * st.interval, adev: adev})
+				}
+			}
+		}
+		stats = append(stats, st)
+	}
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].server < stats[j].server })
+	return stats
+}
+
+// вывод статистики в виде таблицы
+func writeStats(w io.Writer, stats []serverStats) {
+	sec := func(v float64) time.Duration {
+		return time.Duration(v * float64(time.Second))
+	}
+
+	fmt.Fprintf(w, "%-30s %8s %8s %14s %14s %14s %14s\n", "server", "samples", "avail", "min", "max", "mean", "jitter")
+	for _, st := range stats {
+		avail := 100 * float64(st.ok) / float64(st.total)
+		if st.ok == 0 {
+			fmt.Fprintf(w, "%-30s %8d %7.1f%% %14s %14s %14s %14s\n", st.server, st.total, avail, "-", "-", "-", "-")
+			continue
+		}
+		fmt.Fprintf(w, "%-30s %8d %7.1f%% %14v %14v %14v %14v\n", st.server, st.total, avail,
+			sec(st.min), sec(st.max), sec(st.mean), sec(st.jitter))
+		for _, p := range st.deviation {
+			fmt.Fprintf(w, "    adev(tau=%v) = %.3e\n", p.tau, p.adev)
+		}
+	}
+}
+
+// режим analyze: статистика по журналу измерений
+func runAnalyze(args []string) {
+	fs := flag.NewFlagSet("analyze", flag.ExitOnError)
+	tauList := fs.String("tau", "", "comma-separated averaging intervals for Allan deviation (powers of two of the poll interval if empty)")
+	fs.Parse(args)
+
+	var taus []time.Duration
+	for _, s := range strings.Split(*tauList, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		tau, err := time.ParseDuration(s)
+		if err != nil {
+			log.Fatalf("invalid tau: %v", err)
+		}
+		taus = append(taus, tau)
+	}
+
+	//журналы из аргументов или из os.Stdin
+	var records []logRecord
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, name := range files {
+		var input io.Reader = os.Stdin
+		var file *os.File
+		if name != "-" {
+			var err error
+			if file, err = os.Open(name); err != nil {
+				log.Fatalf("opening file error: %v", err)
+			}
+			input = file
+		}
+		list, err := readLog(input)
+		if file != nil {
+			// закрываем сразу, а не в конце runAnalyze
+			file.Close()
+		}
+		if err != nil {
+			log.Fatalf("%s: %v", name, err)
+		}
+		records = append(records, list...)
+	}
+
+	writeStats(os.Stdout, analyze(records, taus))
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"WB_L2/L2_8/clock"
+	"github.com/beevik/ntp"
+)
+
+func TestAllanDeviation(t *testing.T) {
+	tau0 := time.Second
+
+	// постоянный дрейф частоты не дает вклада в девиацию Аллана
+	linear := make([]float64, 10)
+	for i := range linear {
+		linear[i] = 1e-6 * float64(i)
+	}
+	if adev, ok := allanDeviation(linear, 1, tau0); !ok || adev > 1e-15 {
+		t.Errorf("expected zero deviation for linear phase, got %v (%v)", adev, ok)
+	}
+
+	// чередование 0, a, 0, a: вторые разности равны ±2a
+	alt := []float64{0, 1e-3, 0, 1e-3, 0}
+	adev, ok := allanDeviation(alt, 1, tau0)
+	if want := math.Sqrt(4e-6 / 2); !ok || math.Abs(adev-want) > 1e-12 {
+		t.Errorf("got %v, want %v", adev, want)
+	}
+
+	if _, ok := allanDeviation(alt, 3, tau0); ok {
+		t.Error("expected no result when tau is too long")
+	}
+}
+
+func TestAnalyzeLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "samples.jsonl")
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 8; i++ {
+		now := start.Add(time.Duration(i) * time.Minute)
+		offset := time.Duration(10+2*(i%2)) * time.Millisecond
+		res := clock.Consensus{Truechimers: []clock.Sample{{Server: "a", Response: &ntp.Response{ClockOffset: offset}}}}
+		if i%4 == 3 {
+			res.Failed = []clock.Sample{{Server: "b", Err: errors.New("timeout")}}
+		} else {
+			res.Truechimers = append(res.Truechimers, clock.Sample{Server: "b", Response: &ntp.Response{}})
+		}
+		if err := appendLog(path, consensusRecords(now, res)); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer file.Close()
+	records, err := readLog(file)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	stats := analyze(records, []time.Duration{2 * time.Minute})
+	if len(stats) != 2 || stats[0].server != "a" || stats[1].server != "b" {
+		t.Fatalf("unexpected servers: %+v", stats)
+	}
+
+	a := stats[0]
+	if a.total != 8 || a.ok != 8 || a.interval != time.Minute {
+		t.Errorf("a: got %d/%d samples every %v", a.ok, a.total, a.interval)
+	}
+	if math.Abs(a.min-0.010) > 1e-9 || math.Abs(a.max-0.012) > 1e-9 || math.Abs(a.mean-0.011) > 1e-9 {
+		t.Errorf("a: got min %v max %v mean %v", a.min, a.max, a.mean)
+	}
+	if math.Abs(a.jitter-0.002) > 1e-9 {
+		t.Errorf("a: got jitter %v", a.jitter)
+	}
+	if len(a.deviation) != 1 || a.deviation[0].tau != 2*time.Minute {
+		t.Errorf("a: unexpected deviation %+v", a.deviation)
+	}
+	if b := stats[1]; b.total != 8 || b.ok != 6 {
+		t.Errorf("b: got %d/%d samples", b.ok, b.total)
+	}
+
+	var buf bytes.Buffer
+	writeStats(&buf, stats)
+	if !strings.Contains(buf.String(), "75.0%") {
+		t.Errorf("expected availability of b in output:\n%s", buf.String())
+	}
+}
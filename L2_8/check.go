@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"WB_L2/L2_8/clock"
+)
+
+// названия состояний плагина мониторинга
+var checkStates = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// проверка смещения часов: состояние и однострочный вывод с perfdata
+func checkStatus(res clock.Consensus, err error, warn, crit time.Duration, total int) (int, string) {
+	if errors.Is(err, clock.ErrNoConsensus) {
+		//сервера ответили, но расходятся - время ненадежно
+		return alertCritical, fmt.Sprintf("NTP CRITICAL: %v, %d servers queried", err, total)
+	}
+	if err != nil {
+		//сервера не ответили - судить о часах нельзя
+		return alertUnknown, fmt.Sprintf("NTP UNKNOWN: error of take data: %v", err)
+	}
+
+	state := offsetLevel(res.Offset, warn, crit)
+	agreed := len(res.Truechimers)
+	return state, fmt.Sprintf("NTP %s: offset %.6f seconds, %d/%d servers agree|offset=%.6fs;%.6f;%.6f;; servers=%d;;;0;%d",
+		checkStates[state], res.Offset.Seconds(), agreed, total,
+		res.Offset.Seconds(), warn.Seconds(), crit.Seconds(), agreed, total)
+}
+
+// режим check: проверка для Nagios/Icinga, возвращает код выхода
+func runCheck(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	fs.SetOutput(out)
+	query := addQueryFlags(fs, "servers", defaultServers, "comma-separated list of NTP servers")
+	warn := fs.Duration("warn", 100*time.Millisecond, "offset warning threshold")
+	crit := fs.Duration("crit", time.Second, "offset critical threshold")
+	if err := fs.Parse(args); err != nil {
+		return alertUnknown
+	}
+
+	params, err := query.parse()
+	if err != nil {
+		fmt.Fprintf(out, "NTP UNKNOWN: %v\n", err)
+		return alertUnknown
+	}
+	if len(params.servers) == 0 {
+		fmt.Fprintf(out, "NTP UNKNOWN: %v\n", clock.ErrNoServers)
+		return alertUnknown
+	}
+	if *crit > 0 && *warn > *crit {
+		fmt.Fprintln(out, "NTP UNKNOWN: warning threshold exceeds critical threshold")
+		return alertUnknown
+	}
+
+	res, err := params.run()
+	state, line := checkStatus(res, err, *warn, *crit, len(params.servers))
+	fmt.Fprintln(out, line)
+	return state
+}
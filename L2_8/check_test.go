@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"WB_L2/L2_8/clock"
+	"github.com/beevik/ntp"
+)
+
+func TestCheckStatus(t *testing.T) {
+	agreed := []clock.Sample{{Server: "a", Response: &ntp.Response{}}, {Server: "b", Response: &ntp.Response{}}}
+
+	tests := []struct {
+		name   string
+		res    clock.Consensus
+		err    error
+		state  int
+		output string
+	}{
+		{
+			name:   "ok",
+			res:    clock.Consensus{Offset: 5 * time.Millisecond, Truechimers: agreed},
+			state:  alertOK,
+			output: "NTP OK: offset 0.005000 seconds, 2/3 servers agree|offset=0.005000s;0.100000;1.000000;; servers=2;;;0;3",
+		},
+		{
+			name:   "warning",
+			res:    clock.Consensus{Offset: -150 * time.Millisecond, Truechimers: agreed},
+			state:  alertWarning,
+			output: "NTP WARNING: offset -0.150000 seconds, 2/3 servers agree|offset=-0.150000s;0.100000;1.000000;; servers=2;;;0;3",
+		},
+		{
+			name:   "critical",
+			res:    clock.Consensus{Offset: 3 * time.Second, Truechimers: agreed},
+			state:  alertCritical,
+			output: "NTP CRITICAL: offset 3.000000 seconds, 2/3 servers agree|offset=3.000000s;0.100000;1.000000;; servers=2;;;0;3",
+		},
+		{
+			name:   "no consensus",
+			err:    clock.ErrNoConsensus,
+			state:  alertCritical,
+			output: "NTP CRITICAL: no majority of servers agree on time, 3 servers queried",
+		},
+		{
+			name:   "no responses",
+			err:    clock.ErrNoResponses,
+			state:  alertUnknown,
+			output: "NTP UNKNOWN: error of take data: no valid responses from servers",
+		},
+		{
+			name:   "unknown",
+			err:    errors.New("i/o timeout"),
+			state:  alertUnknown,
+			output: "NTP UNKNOWN: error of take data: i/o timeout",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, output := checkStatus(tt.res, tt.err, 100*time.Millisecond, time.Second, 3)
+			if state != tt.state {
+				t.Errorf("got state %d, want %d", state, tt.state)
+			}
+			if output != tt.output {
+				t.Errorf("got output\n%s\nwant\n%s", output, tt.output)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package clock
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/ntp"
+)
+
+// ErrUnknownKey возвращается, если идентификатор ключа отсутствует в файле ключей.
+var ErrUnknownKey = errors.New("unknown authentication key")
+
+// типы ключей в файле ntp.keys
+var authTypes = map[string]ntp.AuthType{
+	"MD5":        ntp.AuthMD5,
+	"M":          ntp.AuthMD5,
+	"SHA1":       ntp.AuthSHA1,
+	"SHA256":     ntp.AuthSHA256,
+	"SHA512":     ntp.AuthSHA512,
+	"AES128CMAC": ntp.AuthAES128,
+	"AES256CMAC": ntp.AuthAES256,
+	"AES128":     ntp.AuthAES128,
+	"AES256":     ntp.AuthAES256,
+}
+
+// Keys - ключи симметричной аутентификации по идентификаторам.
+type Keys map[uint16]ntp.AuthOptions
+
+// ParseKeys читает ключи в формате ntp.keys: по одному ключу на строку
+// "keyid type key", комментарии начинаются с "#". Ключ длиннее 20 символов
+// или с префиксом "HEX:" считается шестнадцатеричным.
+func ParseKeys(r io.Reader) (Keys, error) {
+	keys := Keys{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: expected \"keyid type key\"", line)
+		}
+
+		id, err := strconv.ParseUint(fields[0], 10, 16)
+		if err != nil || id == 0 {
+			return nil, fmt.Errorf("line %d: invalid key id %q", line, fields[0])
+		}
+		typ, ok := authTypes[strings.ToUpper(fields[1])]
+		if !ok {
+			return nil, fmt.Errorf("line %d: unsupported key type %q", line, fields[1])
+		}
+		keys[uint16(id)] = ntp.AuthOptions{Type: typ, Key: fields[2], KeyID: uint16(id)}
+	}
+	return keys, scanner.Err()
+}
+
+// LoadKeys читает файл ключей.
+func LoadKeys(path string) (Keys, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	keys, err := ParseKeys(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return keys, nil
+}
+
+// Auth выбирает ключ для каждого сервера: ключ из Servers, если сервер там
+// указан, иначе Default. Нулевой идентификатор означает запрос без аутентификации.
+type Auth struct {
+	Keys    Keys
+	Default uint16
+	Servers map[string]uint16
+}
+
+// ParseKeyIDs разбирает список "id" или "server=id", перечисленных через запятую.
+// Элемент без имени сервера задает ключ по умолчанию.
+func (a *Auth) ParseKeyIDs(list string) error {
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		server, idStr, found := strings.Cut(item, "=")
+		if !found {
+			idStr = server
+		}
+		id, err := strconv.ParseUint(strings.TrimSpace(idStr), 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid key id %q", item)
+		}
+		if !found {
+			a.Default = uint16(id)
+			continue
+		}
+		if a.Servers == nil {
+			a.Servers = map[string]uint16{}
+		}
+		a.Servers[strings.TrimSpace(server)] = uint16(id)
+	}
+	return nil
+}
+
+// Options возвращает параметры аутентификации для сервера.
+func (a *Auth) Options(server string) (ntp.AuthOptions, error) {
+	if a == nil {
+		return ntp.AuthOptions{}, nil
+	}
+	id, ok := a.Servers[server]
+	if !ok {
+		id = a.Default
+	}
+	if id == 0 {
+		return ntp.AuthOptions{}, nil
+	}
+	opt, ok := a.Keys[id]
+	if !ok {
+		return ntp.AuthOptions{}, fmt.Errorf("key %d: %w", id, ErrUnknownKey)
+	}
+	return opt, nil
+}
+
+// понятное описание ошибок аутентификации
+func authError(opt ntp.AuthOptions, err error) error {
+	switch {
+	case opt.Type == ntp.AuthNone:
+		return err
+	case errors.Is(err, ntp.ErrAuthFailed):
+		return fmt.Errorf("response MAC does not match key %d: %w", opt.KeyID, err)
+	case errors.Is(err, ntp.ErrInvalidAuthKey):
+		return fmt.Errorf("key %d is not valid for its type: %w", opt.KeyID, err)
+	}
+	return err
+}
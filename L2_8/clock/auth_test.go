@@ -0,0 +1,74 @@
+package clock
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/beevik/ntp"
+)
+
+func TestParseKeys(t *testing.T) {
+	file := `# ключи внутренних серверов
+1 MD5 secret    # ascii
+2 SHA1 0123456789abcdef0123456789abcdef01234567
+3 AES128CMAC HEX:000102030405060708090a0b0c0d0e0f
+`
+	keys, err := ParseKeys(strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expect := Keys{
+		1: {Type: ntp.AuthMD5, Key: "secret", KeyID: 1},
+		2: {Type: ntp.AuthSHA1, Key: "0123456789abcdef0123456789abcdef01234567", KeyID: 2},
+		3: {Type: ntp.AuthAES128, Key: "HEX:000102030405060708090a0b0c0d0e0f", KeyID: 3},
+	}
+	if len(keys) != len(expect) {
+		t.Fatalf("got %d keys, want %d", len(keys), len(expect))
+	}
+	for id, k := range expect {
+		if keys[id] != k {
+			t.Errorf("key %d: got %+v, want %+v", id, keys[id], k)
+		}
+	}
+
+	for _, bad := range []string{"1 MD5", "x MD5 key", "0 MD5 key", "1 DES key"} {
+		if _, err := ParseKeys(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestAuthOptions(t *testing.T) {
+	a := &Auth{Keys: Keys{
+		1: {Type: ntp.AuthMD5, Key: "one", KeyID: 1},
+		2: {Type: ntp.AuthSHA1, Key: "two", KeyID: 2},
+	}}
+	if err := a.ParseKeyIDs("1, b.example=2, c.example=0"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		server string
+		keyID  uint16
+		err    error
+	}{
+		{server: "a.example", keyID: 1},
+		{server: "b.example", keyID: 2},
+		{server: "c.example", keyID: 0},
+	}
+	for _, tt := range tests {
+		opt, err := a.Options(tt.server)
+		if err != nil || opt.KeyID != tt.keyID {
+			t.Errorf("%s: got key %d (%v), want %d", tt.server, opt.KeyID, err, tt.keyID)
+		}
+	}
+
+	a.Default = 7
+	if _, err := a.Options("a.example"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey, got %v", err)
+	}
+	if err := a.ParseKeyIDs("x=y"); err == nil {
+		t.Error("expected error for invalid key id")
+	}
+}
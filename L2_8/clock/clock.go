@@ -0,0 +1,169 @@
+// Package clock предоставляет часы, скорректированные по NTP: локальное время
+// плюс смещение, измеренное по нескольким серверам с отбрасыванием выбросов.
+package clock
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+// Clock - источник текущего времени.
+type Clock interface {
+	Now() time.Time
+}
+
+// System - локальные системные часы без коррекции.
+type System struct{}
+
+// Now возвращает локальное время.
+func (System) Now() time.Time {
+	return time.Now()
+}
+
+// Config задает параметры часов NTP.
+type Config struct {
+	// Servers - опрашиваемые NTP-серверы.
+	Servers []string
+
+	// Interval - период фоновой пересинхронизации, по умолчанию 64 секунды.
+	Interval time.Duration
+
+	// MaxAge - время без успешной синхронизации, после которого часы
+	// считаются устаревшими. По умолчанию четыре интервала.
+	MaxAge time.Duration
+
+	// Options передаются в каждый запрос к серверу.
+	Options ntp.QueryOptions
+
+	// Auth задает ключи аутентификации серверов, nil - без аутентификации.
+	Auth *Auth
+}
+
+// NTP - часы, скорректированные по последнему измеренному смещению.
+// До первой успешной синхронизации Now возвращает локальное время.
+type NTP struct {
+	cfg Config
+
+	mu       sync.RWMutex
+	offset   time.Duration
+	lastSync time.Time
+	last     Consensus
+	err      error
+}
+
+// NewNTP создает часы NTP. Синхронизация выполняется вызовом Sync
+// или в фоне после Start.
+func NewNTP(cfg Config) *NTP {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 64 * time.Second
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = 4 * cfg.Interval
+	}
+	return &NTP{cfg: cfg}
+}
+
+// Now возвращает локальное время с поправкой на смещение.
+func (c *NTP) Now() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return time.Now().Add(c.offset)
+}
+
+// Offset возвращает последнее измеренное смещение локальных часов.
+func (c *NTP) Offset() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.offset
+}
+
+// LastSync возвращает момент последней успешной синхронизации
+// (нулевое время, если синхронизаций не было).
+func (c *NTP) LastSync() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastSync
+}
+
+// Last возвращает результат последней успешной синхронизации и ошибку
+// последней попытки (nil, если она удалась).
+func (c *NTP) Last() (Consensus, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.last, c.err
+}
+
+// Stale сообщает, что часы не синхронизировались дольше MaxAge.
+func (c *NTP) Stale() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastSync.IsZero() || time.Since(c.lastSync) > c.cfg.MaxAge
+}
+
+// Sync опрашивает серверы и обновляет смещение. При ошибке сохраняются
+// прежнее смещение и результат последней успешной синхронизации.
+func (c *NTP) Sync() error {
+	res, err := SelectConsensus(QueryAll(c.cfg.Servers, c.cfg.Options, c.cfg.Auth))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+	if err != nil {
+		return err
+	}
+	c.last, c.offset = res, res.Offset
+	c.lastSync = time.Now()
+	return nil
+}
+
+// Start запускает фоновую пересинхронизацию до отмены ctx.
+// Первая синхронизация выполняется сразу.
+func (c *NTP) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(c.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			c.Sync()
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Fake - управляемые часы для тестов.
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFake создает часы, показывающие t.
+func NewFake(t time.Time) *Fake {
+	return &Fake{now: t}
+}
+
+// Now возвращает установленное время.
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Set устанавливает текущее время.
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	f.now = t
+	f.mu.Unlock()
+}
+
+// Advance сдвигает часы на d.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	f.now = f.now.Add(d)
+	f.mu.Unlock()
+}
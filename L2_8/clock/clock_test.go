@@ -0,0 +1,154 @@
+package clock
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var c Clock = NewFake(start)
+	c.(*Fake).Advance(time.Minute)
+	if got := c.Now(); !got.Equal(start.Add(time.Minute)) {
+		t.Errorf("got %v, want %v", got, start.Add(time.Minute))
+	}
+}
+
+func TestNTPFailedSync(t *testing.T) {
+	c := NewNTP(Config{
+		Servers: []string{"127.0.0.1:9"},
+		Options: ntp.QueryOptions{Timeout: 100 * time.Millisecond},
+	})
+	if err := c.Sync(); err == nil {
+		t.Fatal("expected sync error")
+	}
+	// без синхронизации часы устаревшие и показывают локальное время
+	if !c.Stale() {
+		t.Error("expected stale clock")
+	}
+	if c.Offset() != 0 || !c.LastSync().IsZero() {
+		t.Errorf("unexpected offset %v or last sync %v", c.Offset(), c.LastSync())
+	}
+	if d := time.Since(c.Now()); d < 0 || d > time.Second {
+		t.Errorf("expected local time, got difference %v", d)
+	}
+}
+
+// startResponder запускает на loopback простейший NTP-сервер, часы которого
+// спешат на offset; stop закрывает его, и дальнейшие запросы не проходят
+func startResponder(t *testing.T, offset time.Duration) (addr string, stop func()) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < 48 {
+				continue
+			}
+			now := ntpTime(time.Now().Add(offset))
+			resp := make([]byte, 48)
+			resp[0] = 4<<3 | 4 // LI=0, версия 4, режим сервера
+			resp[1] = 2        // stratum
+			resp[3] = 0xec     // точность 2^-20 с
+			// root dispersion 10 мс: на loopback задержка почти нулевая
+			binary.BigEndian.PutUint32(resp[8:], 10*(1<<16)/1000)
+			copy(resp[12:16], "TEST")
+			binary.BigEndian.PutUint64(resp[16:], now)
+			copy(resp[24:32], buf[40:48]) // originate = transmit клиента
+			binary.BigEndian.PutUint64(resp[32:], now)
+			binary.BigEndian.PutUint64(resp[40:], now)
+			conn.WriteTo(resp, from)
+		}
+	}()
+	t.Cleanup(func() { conn.Close() })
+	return conn.LocalAddr().String(), func() { conn.Close() }
+}
+
+// время в 64-битном формате NTP (32.32)
+func ntpTime(t time.Time) uint64 {
+	d := t.Sub(time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC))
+	return uint64(d/time.Second)<<32 | uint64(d%time.Second)<<32/uint64(time.Second)
+}
+
+// near сообщает, что d отличается от want не больше чем на 100 мс
+func near(d, want time.Duration) bool {
+	return d-want > -100*time.Millisecond && d-want < 100*time.Millisecond
+}
+
+func TestNTPSync(t *testing.T) {
+	addr, stop := startResponder(t, time.Hour)
+	c := NewNTP(Config{Servers: []string{addr}, Options: ntp.QueryOptions{Timeout: 500 * time.Millisecond}})
+
+	before := time.Now()
+	if err := c.Sync(); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	offset, lastSync := c.Offset(), c.LastSync()
+	if !near(offset, time.Hour) {
+		t.Errorf("expected offset about 1h, got %v", offset)
+	}
+	if d := c.Now().Sub(time.Now()); !near(d, time.Hour) {
+		t.Errorf("expected Now about 1h ahead, got %v", d)
+	}
+	if lastSync.Before(before) || lastSync.After(time.Now()) {
+		t.Errorf("unexpected last sync %v", lastSync)
+	}
+	if c.Stale() {
+		t.Error("expected fresh clock after sync")
+	}
+	if res, err := c.Last(); err != nil || res.Offset != offset || len(res.Truechimers) != 1 {
+		t.Errorf("unexpected last result %+v, %v", res, err)
+	}
+
+	// после неудачной синхронизации остаются прежние смещение и результат
+	stop()
+	if err := c.Sync(); err == nil {
+		t.Fatal("expected sync error after server stopped")
+	}
+	if c.Offset() != offset || !c.LastSync().Equal(lastSync) {
+		t.Errorf("offset %v or last sync %v changed after failure", c.Offset(), c.LastSync())
+	}
+	if res, err := c.Last(); err == nil || res.Offset != offset {
+		t.Errorf("expected previous result with error, got %+v, %v", res, err)
+	}
+}
+
+func TestNTPStart(t *testing.T) {
+	addr, _ := startResponder(t, -time.Minute)
+	c := NewNTP(Config{
+		Servers:  []string{addr},
+		Interval: 20 * time.Millisecond,
+		Options:  ntp.QueryOptions{Timeout: 500 * time.Millisecond},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.Start(ctx)
+
+	// первая синхронизация сразу, затем повторные по Interval
+	var first time.Time
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if last := c.LastSync(); first.IsZero() {
+			first = last
+		} else if last.After(first) {
+			if !near(c.Offset(), -time.Minute) || c.Stale() {
+				t.Errorf("unexpected offset %v or stale clock", c.Offset())
+			}
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("no background resync, last sync %v", c.LastSync())
+}
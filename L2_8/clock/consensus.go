@@ -0,0 +1,193 @@
+package clock
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+// ошибки выбора времени
+var (
+	ErrNoServers   = errors.New("no servers specified")
+	ErrNoResponses = errors.New("no valid responses from servers")
+	ErrNoConsensus = errors.New("no majority of servers agree on time")
+)
+
+// Sample содержит результат опроса одного сервера. При ошибке запроса
+// Response равен nil, при ошибке Validate ответ сохраняется вместе с ошибкой.
+type Sample struct {
+	Server   string
+	Response *ntp.Response
+	Err      error
+}
+
+// интервал корректности сервера: истинное смещение часов лежит в
+// [offset-λ, offset+λ], где λ - root distance (но не меньше половины RTT)
+func (s Sample) interval() (time.Duration, time.Duration) {
+	lambda := s.Response.RootDistance
+	if half := s.Response.RTT / 2; lambda < half {
+		lambda = half
+	}
+	return s.Response.ClockOffset - lambda, s.Response.ClockOffset + lambda
+}
+
+// Consensus - итог выбора времени по нескольким серверам.
+type Consensus struct {
+	Offset       time.Duration // итоговое смещение локальных часов
+	Low, High    time.Duration // пересечение интервалов честных серверов
+	Truechimers  []Sample      // серверы, вошедшие в пересечение
+	Falsetickers []Sample      // серверы, отброшенные как выбросы
+	Failed       []Sample      // серверы, которые не ответили или ответили некорректно
+}
+
+// SplitServers разбирает список серверов, перечисленных через запятую.
+func SplitServers(list string) []string {
+	var servers []string
+	for _, s := range strings.Split(list, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			servers = append(servers, s)
+		}
+	}
+	return servers
+}
+
+// QueryAll опрашивает все серверы параллельно. Порядок результатов
+// совпадает с порядком серверов. Если auth не nil, запросы к серверам
+// подписываются выбранными для них ключами, а ответы с неверной подписью
+// считаются ошибкой.
+func QueryAll(servers []string, opt ntp.QueryOptions, auth *Auth) []Sample {
+	samples := make([]Sample, len(servers))
+	wg := &sync.WaitGroup{}
+	for i, server := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			samples[i] = query(server, opt, auth)
+		}()
+	}
+	wg.Wait()
+	return samples
+}
+
+// опрос одного сервера
+func query(server string, opt ntp.QueryOptions, auth *Auth) Sample {
+	var err error
+	if opt.Auth, err = auth.Options(server); err != nil {
+		return Sample{Server: server, Err: err}
+	}
+	resp, err := ntp.QueryWithOptions(server, opt)
+	if err == nil {
+		// ответ, непригодный для синхронизации, считаем ошибкой
+		err = resp.Validate()
+	}
+	if err != nil {
+		err = authError(opt.Auth, err)
+	}
+	return Sample{Server: server, Response: resp, Err: err}
+}
+
+// SelectConsensus выбирает согласованное время по алгоритму пересечения
+// интервалов (Marzullo, RFC 5905): ищется наименьшее число выбросов f < n/2,
+// при котором хотя бы n-f интервалов имеют общую точку, а смещения не более
+// f серверов лежат вне пересечения. Серверы, чье смещение вне пересечения,
+// считаются выбросами, итоговое смещение - среднее смещений честных серверов
+// с весом 1/λ.
+func SelectConsensus(samples []Sample) (Consensus, error) {
+	var res Consensus
+	if len(samples) == 0 {
+		return res, ErrNoServers
+	}
+
+	var valid []Sample
+	for _, s := range samples {
+		if s.Err != nil {
+			res.Failed = append(res.Failed, s)
+		} else {
+			valid = append(valid, s)
+		}
+	}
+	if len(valid) == 0 {
+		return res, ErrNoResponses
+	}
+
+	// границы интервалов: нижние со знаком +1, верхние со знаком -1
+	type edge struct {
+		offset time.Duration
+		kind   int
+	}
+	edges := make([]edge, 0, 2*len(valid))
+	for _, s := range valid {
+		lo, hi := s.interval()
+		edges = append(edges, edge{lo, +1}, edge{hi, -1})
+	}
+	// при равенстве нижняя граница идет раньше, чтобы касающиеся интервалы пересекались
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].offset != edges[j].offset {
+			return edges[i].offset < edges[j].offset
+		}
+		return edges[i].kind > edges[j].kind
+	})
+
+	n := len(valid)
+	for f := 0; 2*f < n; f++ {
+		need := n - f
+
+		// нижняя граница: первая точка слева, покрытая need интервалами
+		low, foundLow, count := time.Duration(0), false, 0
+		for _, e := range edges {
+			count += e.kind
+			if count >= need {
+				low, foundLow = e.offset, true
+				break
+			}
+		}
+
+		// верхняя граница: первая точка справа, покрытая need интервалами
+		high, foundHigh, count := time.Duration(0), false, 0
+		for i := len(edges) - 1; i >= 0; i-- {
+			count -= edges[i].kind
+			if count >= need {
+				high, foundHigh = edges[i].offset, true
+				break
+			}
+		}
+
+		if !foundLow || !foundHigh || low > high {
+			continue
+		}
+
+		// честным считается сервер, чье смещение (середина интервала) лежит в
+		// пересечении; если таких меньше n-f, пересечение не годится
+		var truechimers, falsetickers []Sample
+		for _, s := range valid {
+			if offset := s.Response.ClockOffset; offset < low || offset > high {
+				falsetickers = append(falsetickers, s)
+			} else {
+				truechimers = append(truechimers, s)
+			}
+		}
+		if len(falsetickers) > f {
+			continue
+		}
+
+		res.Low, res.High = low, high
+		res.Truechimers, res.Falsetickers = truechimers, falsetickers
+		var sum, weights float64
+		for _, s := range truechimers {
+			lo, hi := s.interval()
+			w := 1 / float64(hi-lo+1)
+			sum += w * float64(s.Response.ClockOffset)
+			weights += w
+		}
+		res.Offset = time.Duration(sum / weights)
+		return res, nil
+	}
+
+	// согласия нет - ни одному серверу доверять нельзя
+	res.Falsetickers = valid
+	return res, ErrNoConsensus
+}
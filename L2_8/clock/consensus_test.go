@@ -0,0 +1,119 @@
+package clock
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+// сэмпл с заданным смещением и root distance (в миллисекундах)
+func ms(server string, offset, distance int) Sample {
+	return Sample{Server: server, Response: &ntp.Response{
+		ClockOffset:  time.Duration(offset) * time.Millisecond,
+		RootDistance: time.Duration(distance) * time.Millisecond,
+	}}
+}
+
+// имена серверов из списка результатов
+func names(samples []Sample) []string {
+	var res []string
+	for _, s := range samples {
+		res = append(res, s.Server)
+	}
+	return res
+}
+
+func TestSelectConsensus(t *testing.T) {
+	failed := Sample{Server: "down", Err: errors.New("timeout")}
+
+	tests := []struct {
+		name         string
+		samples      []Sample
+		truechimers  []string
+		falsetickers []string
+		offset       time.Duration
+		err          error
+	}{
+		{
+			name:        "single server",
+			samples:     []Sample{ms("a", 10, 5)},
+			truechimers: []string{"a"},
+			offset:      10 * time.Millisecond,
+		},
+		{
+			name:        "all agree",
+			samples:     []Sample{ms("a", 10, 5), ms("b", 12, 5), ms("c", 14, 5)},
+			truechimers: []string{"a", "b", "c"},
+			offset:      12 * time.Millisecond,
+		},
+		{
+			//один сервер врет на минуту
+			name:         "one falseticker",
+			samples:      []Sample{ms("a", 10, 5), ms("bad", 60000, 5), ms("c", 12, 5)},
+			truechimers:  []string{"a", "c"},
+			falsetickers: []string{"bad"},
+			offset:       11 * time.Millisecond,
+		},
+		{
+			// интервал выброса задевает пересечение, но его смещение вне его
+			name:         "wide falseticker overlaps",
+			samples:      []Sample{ms("a", 0, 5), ms("b", 2, 5), ms("bad", 500, 496)},
+			truechimers:  []string{"a", "b"},
+			falsetickers: []string{"bad"},
+			offset:       1 * time.Millisecond,
+		},
+		{
+			name:         "wide falseticker with precise server",
+			samples:      []Sample{ms("a", 0, 10), ms("b", 1, 1), ms("c", 100, 95)},
+			truechimers:  []string{"a", "b"},
+			falsetickers: []string{"c"},
+			offset:       909091 * time.Nanosecond,
+		},
+		{
+			//недоступный сервер не участвует в выборе
+			name:        "failed server ignored",
+			samples:     []Sample{ms("a", 10, 5), failed, ms("c", 10, 5)},
+			truechimers: []string{"a", "c"},
+			offset:      10 * time.Millisecond,
+		},
+		{
+			//два сервера расходятся - большинства нет
+			name:    "no majority",
+			samples: []Sample{ms("a", 0, 5), ms("b", 1000, 5)},
+			err:     ErrNoConsensus,
+		},
+		{
+			name:    "nothing answered",
+			samples: []Sample{failed},
+			err:     ErrNoResponses,
+		},
+		{
+			name: "no servers",
+			err:  ErrNoServers,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := SelectConsensus(tt.samples)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if got := names(res.Truechimers); !reflect.DeepEqual(got, tt.truechimers) {
+				t.Errorf("truechimers: got %v, want %v", got, tt.truechimers)
+			}
+			if got := names(res.Falsetickers); len(got)+len(tt.falsetickers) > 0 && !reflect.DeepEqual(got, tt.falsetickers) {
+				t.Errorf("falsetickers: got %v, want %v", got, tt.falsetickers)
+			}
+			if diff := res.Offset - tt.offset; diff < -time.Microsecond || diff > time.Microsecond {
+				t.Errorf("offset: got %v, want %v", res.Offset, tt.offset)
+			}
+		})
+	}
+}
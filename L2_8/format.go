@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// именованные форматы вывода времени
+var layouts = map[string]string{
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+	"rfc1123":     time.RFC1123,
+	"rfc1123z":    time.RFC1123Z,
+	"rfc822":      time.RFC822,
+	"rfc822z":     time.RFC822Z,
+	"ansic":       time.ANSIC,
+	"unixdate":    time.UnixDate,
+	"datetime":    time.DateTime,
+	"stamp":       time.Stamp,
+	"stampmicro":  time.StampMicro,
+}
+
+// timeFormat - часовой пояс и формат вывода времени
+type timeFormat struct {
+	loc    *time.Location
+	layout string // имя формата, unix, unixmilli, unixnano или произвольный layout Go
+}
+
+// разбор флагов -tz и -format
+func newTimeFormat(zone, layout string) (timeFormat, error) {
+	loc := time.Local
+	if zone != "" && zone != "Local" {
+		var err error
+		if loc, err = time.LoadLocation(zone); err != nil {
+			return timeFormat{}, err
+		}
+	}
+	return timeFormat{loc: loc, layout: layout}, nil
+}
+
+// форматирование времени в заданном часовом поясе
+func (f timeFormat) format(t time.Time) string {
+	t = t.In(f.loc)
+	switch name := strings.ToLower(f.layout); name {
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "unixmilli":
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	case "unixnano":
+		return strconv.FormatInt(t.UnixNano(), 10)
+	default:
+		if layout, ok := layouts[name]; ok {
+			return t.Format(layout)
+		}
+	}
+	return t.Format(f.layout)
+}
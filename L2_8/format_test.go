@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeFormat(t *testing.T) {
+	// 2024-03-01 12:30:45.5 UTC
+	tm := time.Unix(1709296245, 500000000)
+
+	tests := []struct {
+		zone   string
+		layout string
+		expect string
+	}{
+		{zone: "UTC", layout: "rfc3339", expect: "2024-03-01T12:30:45Z"},
+		{zone: "Europe/Moscow", layout: "RFC3339", expect: "2024-03-01T15:30:45+03:00"},
+		{zone: "UTC", layout: "rfc3339nano", expect: "2024-03-01T12:30:45.5Z"},
+		{zone: "Asia/Tokyo", layout: "rfc1123", expect: "Fri, 01 Mar 2024 21:30:45 JST"},
+		{zone: "UTC", layout: "stamp", expect: "Mar  1 12:30:45"},
+		{zone: "UTC", layout: "stampmicro", expect: "Mar  1 12:30:45.500000"},
+		{zone: "UTC", layout: "unix", expect: "1709296245"},
+		{zone: "UTC", layout: "unixmilli", expect: "1709296245500"},
+		{zone: "UTC", layout: "unixnano", expect: "1709296245500000000"},
+		{zone: "America/New_York", layout: "2006-01-02 15:04 MST", expect: "2024-03-01 07:30 EST"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.zone+" "+tt.layout, func(t *testing.T) {
+			tf, err := newTimeFormat(tt.zone, tt.layout)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := tf.format(tm); got != tt.expect {
+				t.Errorf("got %q, want %q", got, tt.expect)
+			}
+		})
+	}
+
+	if _, err := newTimeFormat("Mars/Olympus", "rfc3339"); err == nil {
+		t.Error("expected error for unknown time zone")
+	}
+}
@@ -1,24 +1,147 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
-	"github.com/beevik/ntp"
 	"log"
 	"os"
+	"strings"
+	"time"
+
+	"WB_L2/L2_8/clock"
+	"WB_L2/L2_8/source"
 )
 
+// список серверов по умолчанию: российские серверы stratum 1
+const defaultServers = "ntp1.stratum1.ru,ntp2.stratum1.ru,ntp3.stratum1.ru,ntp4.stratum1.ru,ntp5.stratum1.ru"
+
+type config struct {
+	sources *source.Fallback
+	verbose bool
+	json    bool
+	format  timeFormat
+	logPath string
+}
+
 func main() {
-	//функция time обращается к ntp-серверу по указанному адресу
-	//ntp серверов существует множество, я взял российский, который выводит текущее московское время
-	currentTime, err := ntp.Time("ntp1.stratum1.ru")
+	//подкоманды
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "serve":
+			runServe(os.Args[2:])
+			return
+		case "monitor":
+			runMonitor(os.Args[2:])
+			return
+		case "check":
+			os.Exit(runCheck(os.Args[2:], os.Stdout))
+		case "analyze":
+			runAnalyze(os.Args[2:])
+			return
+		}
+	}
+
+	cfg := parseFlags()
+
+	//опрашиваем источники по порядку: серверы NTP одновременно с выбором
+	//согласованного времени, при неудаче - резервные источники
+	var fallback bool
+	cfg.sources.OnError = func(name string, err error) {
+		fallback = true
+		if !cfg.json {
+			log.Printf("%s source failed: %v", name, err)
+		}
+	}
+	m, err := cfg.sources.Measure(context.Background())
+	if fallback && err == nil && !cfg.json {
+		log.Printf("fell back to %s source", m.Source)
+	}
+
+	//дописываем измерения в журнал
+	if cfg.logPath != "" {
+		if err := appendLog(cfg.logPath, measurementRecords(time.Now(), m, err)); err != nil {
+			log.Printf("writing log error: %v", err)
+		}
+	}
+
+	//в диагностическом режиме выводим полный отчет по каждому серверу
+	if cfg.verbose || cfg.json {
+		r := newReport(time.Now(), m, err, cfg.format)
+		if cfg.json {
+			if err := writeReportJSON(os.Stdout, r); err != nil {
+				log.Fatalf("writing report error: %v", err)
+			}
+		} else {
+			writeReportText(os.Stdout, r)
+		}
+		if err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
 	if err != nil {
-		//логируем ошибку
+		//логируем ошибку и причины отказа каждого сервера
 		log.Printf("error of take data: %v", err)
+		if m.Consensus != nil {
+			for _, s := range m.Consensus.Failed {
+				log.Printf("%s: %v", s.Server, s.Err)
+			}
+		}
 		//выходим с кодом ошибки != 0
 		os.Exit(1)
 	}
-	//выводим результат
-	fmt.Println("(Moscow) current time: ", currentTime)
+
+	//выводим результат: в stdout только время, чтобы его было удобно разбирать в скриптах,
+	//сведения об источнике - в stderr
+	fmt.Println(cfg.format.format(time.Now().Add(m.Offset)))
+	if res := m.Consensus; res != nil {
+		log.Printf("agreed servers: %s", strings.Join(serverNames(res.Truechimers), ", "))
+		if len(res.Falsetickers) > 0 {
+			log.Printf("falsetickers: %s", strings.Join(serverNames(res.Falsetickers), ", "))
+		}
+		for _, s := range res.Failed {
+			log.Printf("unreachable: %s (%v)", s.Server, s.Err)
+		}
+	} else {
+		log.Printf("time source: %s (±%v)", m.Source, m.Uncertainty)
+	}
+}
+
+func parseFlags() config {
+	var cfg config
+	query := addQueryFlags(flag.CommandLine, "servers", defaultServers, "comma-separated list of NTP servers")
+	sources := addSourceFlags(flag.CommandLine)
+	flag.BoolVar(&cfg.verbose, "v", false, "print detailed diagnostics for each server")
+	flag.BoolVar(&cfg.json, "json", false, "print time and diagnostics as JSON")
+	flag.StringVar(&cfg.logPath, "log", "", "append each measurement to this JSON Lines file")
+	zone := flag.String("tz", "Local", "IANA time zone for output, e.g. Europe/Moscow or UTC")
+	layout := flag.String("format", "rfc3339", "output format: rfc3339, rfc3339nano, rfc1123, rfc1123z, rfc822, rfc822z, ansic, unixdate, datetime, stamp, stampmicro, unix, unixmilli, unixnano or a Go time layout")
+	flag.Parse()
+
+	var err error
+	if cfg.format, err = newTimeFormat(*zone, *layout); err != nil {
+		log.Fatalf("time zone error: %v", err)
+	}
+
+	params, err := query.parse()
+	if err != nil {
+		log.Fatalf("authentication keys error: %v", err)
+	}
+	if cfg.sources, err = sources.build(params); err != nil {
+		log.Fatal(err)
+	}
+	return cfg
+}
+
+// имена серверов из списка результатов
+func serverNames(samples []clock.Sample) []string {
+	names := make([]string, len(samples))
+	for i, s := range samples {
+		names[i] = s.Server
+	}
+	return names
 }
 
 //
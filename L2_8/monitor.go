@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"WB_L2/L2_8/clock"
+)
+
+// уровни тревоги, совпадают с кодами выхода плагинов Nagios/Icinga
+const (
+	alertOK = iota
+	alertWarning
+	alertCritical
+	alertUnknown
+)
+
+// point - одно успешное измерение смещения
+type point struct {
+	at     time.Time
+	offset time.Duration
+}
+
+// monitor - состояние наблюдения за смещением часов
+type monitor struct {
+	size       int           // сколько измерений хранить для оценки дрейфа
+	warn, crit time.Duration // пороги смещения для тревоги
+
+	mu          sync.Mutex
+	history     []point
+	last        clock.Consensus // последний успешный результат
+	lastErr     error
+	lastSuccess time.Time
+	failures    int // ошибок подряд
+	total       int // всего ошибок
+}
+
+// учет результата очередного опроса
+func (m *monitor) record(now time.Time, res clock.Consensus, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// при ошибке метрики показывают последний успешный результат, а сбой
+	// виден по счетчику ошибок подряд
+	m.lastErr = err
+	if err != nil {
+		m.failures++
+		m.total++
+		return
+	}
+	m.last, m.failures = res, 0
+	m.lastSuccess = now
+	m.history = append(m.history, point{at: now, offset: res.Offset})
+	if len(m.history) > m.size {
+		m.history = m.history[len(m.history)-m.size:]
+	}
+}
+
+// оценка дрейфа частоты локальных часов в ppm: наклон прямой offset(t),
+// найденной методом наименьших квадратов по истории измерений
+func (m *monitor) drift() float64 {
+	if len(m.history) < 2 {
+		return 0
+	}
+	start := m.history[0].at
+	var sx, sy, sxx, sxy float64
+	for _, p := range m.history {
+		x := p.at.Sub(start).Seconds()
+		y := p.offset.Seconds()
+		sx += x
+		sy += y
+		sxx += x * x
+		sxy += x * y
+	}
+	n := float64(len(m.history))
+	den := n*sxx - sx*sx
+	if den == 0 {
+		return 0
+	}
+	return (n*sxy - sx*sy) / den * 1e6
+}
+
+// текущий уровень тревоги
+func (m *monitor) level() int {
+	if m.lastSuccess.IsZero() || m.lastErr != nil {
+		return alertCritical
+	}
+	return offsetLevel(m.last.Offset, m.warn, m.crit)
+}
+
+// уровень тревоги по модулю смещения, нулевой порог не проверяется
+func offsetLevel(offset, warn, crit time.Duration) int {
+	if offset < 0 {
+		offset = -offset
+	}
+	switch {
+	case crit > 0 && offset >= crit:
+		return alertCritical
+	case warn > 0 && offset >= warn:
+		return alertWarning
+	}
+	return alertOK
+}
+
+// метрики в текстовом формате Prometheus
+func (m *monitor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+
+	metric("ntp_offset_seconds", "gauge", "Offset of the local clock relative to the NTP consensus.")
+	fmt.Fprintf(w, "ntp_offset_seconds %g\n", m.last.Offset.Seconds())
+	metric("ntp_drift_ppm", "gauge", "Estimated frequency drift of the local clock.")
+	fmt.Fprintf(w, "ntp_drift_ppm %g\n", m.drift())
+	metric("ntp_last_success_timestamp_seconds", "gauge", "Unix time of the last successful synchronization.")
+	var last float64
+	if !m.lastSuccess.IsZero() {
+		last = float64(m.lastSuccess.UnixNano()) / 1e9
+	}
+	fmt.Fprintf(w, "ntp_last_success_timestamp_seconds %.3f\n", last)
+	metric("ntp_sync_failures_total", "counter", "Number of failed synchronization attempts.")
+	fmt.Fprintf(w, "ntp_sync_failures_total %d\n", m.total)
+	metric("ntp_sync_consecutive_failures", "gauge", "Number of failed synchronization attempts since the last success.")
+	fmt.Fprintf(w, "ntp_sync_consecutive_failures %d\n", m.failures)
+	metric("ntp_servers_agreed", "gauge", "Number of servers that agreed on the time in the last successful poll.")
+	fmt.Fprintf(w, "ntp_servers_agreed %d\n", len(m.last.Truechimers))
+	metric("ntp_alert_level", "gauge", "Alert level: 0 ok, 1 warning, 2 critical.")
+	fmt.Fprintf(w, "ntp_alert_level %d\n", m.level())
+
+	metric("ntp_server_offset_seconds", "gauge", "Offset reported by each server in the last successful poll.")
+	for _, s := range m.last.Truechimers {
+		fmt.Fprintf(w, "ntp_server_offset_seconds{server=%q,status=\"truechimer\"} %g\n", s.Server, s.Response.ClockOffset.Seconds())
+	}
+	for _, s := range m.last.Falsetickers {
+		fmt.Fprintf(w, "ntp_server_offset_seconds{server=%q,status=\"falseticker\"} %g\n", s.Server, s.Response.ClockOffset.Seconds())
+	}
+}
+
+// пауза до следующего опроса: интервал с разбросом ±10%, после ошибок -
+// экспоненциальная задержка (не больше maxInterval) со случайным разбросом
+func nextDelay(interval, maxInterval time.Duration, failures int) time.Duration {
+	if failures == 0 {
+		jitter := time.Duration(rand.Int63n(int64(interval)/5+1)) - interval/10
+		return interval + jitter
+	}
+	d := interval
+	for i := 0; i < failures && d < maxInterval; i++ {
+		d *= 2
+	}
+	if d > maxInterval {
+		d = maxInterval
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d)/2+1))
+}
+
+// режим monitor: периодический опрос серверов и метрики по HTTP
+func runMonitor(args []string) {
+	fs := flag.NewFlagSet("monitor", flag.ExitOnError)
+	query := addQueryFlags(fs, "servers", defaultServers, "comma-separated list of NTP servers")
+	interval := fs.Duration("interval", 64*time.Second, "polling interval")
+	maxInterval := fs.Duration("max-interval", 15*time.Minute, "maximum backoff interval after failures")
+	listen := fs.String("listen", ":9123", "address of the HTTP metrics endpoint")
+	history := fs.Int("history", 64, "number of measurements used to estimate drift")
+	warn := fs.Duration("warn", 100*time.Millisecond, "offset warning threshold")
+	crit := fs.Duration("crit", time.Second, "offset critical threshold")
+	logPath := fs.String("log", "", "append each measurement to this JSON Lines file")
+	fs.Parse(args)
+	if *interval <= 0 {
+		log.Fatalf("invalid interval %v: must be positive", *interval)
+	}
+	if *maxInterval < *interval {
+		log.Fatalf("invalid max-interval %v: must not be less than interval %v", *maxInterval, *interval)
+	}
+	if *history <= 0 {
+		log.Fatalf("invalid history %d: must be positive", *history)
+	}
+
+	params, err := query.parse()
+	if err != nil {
+		log.Fatalf("authentication keys error: %v", err)
+	}
+	if len(params.servers) == 0 {
+		log.Fatal(clock.ErrNoServers)
+	}
+	m := &monitor{size: *history, warn: *warn, crit: *crit}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	srv := &http.Server{Addr: *listen, Handler: mux}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("metrics server error: %v", err)
+		}
+	}()
+	log.Printf("serving metrics on %s/metrics", *listen)
+
+	prevLevel := alertOK
+	for {
+		res, err := params.run()
+		now := time.Now()
+		m.record(now, res, err)
+		if *logPath != "" {
+			if err := appendLog(*logPath, consensusRecords(now, res)); err != nil {
+				log.Printf("writing log error: %v", err)
+			}
+		}
+
+		m.mu.Lock()
+		level, failures, drift := m.level(), m.failures, m.drift()
+		m.mu.Unlock()
+
+		//сообщаем в лог о смене уровня тревоги
+		switch {
+		case err != nil:
+			log.Printf("error of take data: %v", err)
+		case level != prevLevel && level == alertCritical:
+			log.Printf("CRITICAL: offset %v exceeds %v", res.Offset, *crit)
+		case level != prevLevel && level == alertWarning:
+			log.Printf("WARNING: offset %v exceeds %v", res.Offset, *warn)
+		case level != prevLevel:
+			log.Printf("OK: offset %v, drift %.3f ppm", res.Offset, drift)
+		}
+		prevLevel = level
+
+		select {
+		case <-ctx.Done():
+			shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			srv.Shutdown(shutdown)
+			return
+		case <-time.After(nextDelay(*interval, *maxInterval, failures)):
+		}
+	}
+}
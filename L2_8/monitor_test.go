@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"WB_L2/L2_8/clock"
+	"github.com/beevik/ntp"
+)
+
+func TestMonitorDrift(t *testing.T) {
+	m := &monitor{size: 10}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// часы уходят на 1 мс каждые 100 секунд - это 10 ppm
+	for i := 0; i < 20; i++ {
+		res := clock.Consensus{Offset: time.Duration(i) * time.Millisecond}
+		m.record(start.Add(time.Duration(i)*100*time.Second), res, nil)
+	}
+	if len(m.history) != 10 {
+		t.Errorf("expected history of 10 points, got %d", len(m.history))
+	}
+	if d := m.drift(); math.Abs(d-10) > 1e-6 {
+		t.Errorf("expected drift 10 ppm, got %v", d)
+	}
+}
+
+func TestMonitorLevel(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset time.Duration
+		err    error
+		level  int
+	}{
+		{name: "ok", offset: 10 * time.Millisecond, level: alertOK},
+		{name: "warning", offset: -200 * time.Millisecond, level: alertWarning},
+		{name: "critical", offset: 2 * time.Second, level: alertCritical},
+		{name: "failure", err: errors.New("timeout"), level: alertCritical},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &monitor{size: 10, warn: 100 * time.Millisecond, crit: time.Second}
+			m.record(time.Now(), clock.Consensus{Offset: tt.offset}, tt.err)
+			if got := m.level(); got != tt.level {
+				t.Errorf("got level %d, want %d", got, tt.level)
+			}
+		})
+	}
+}
+
+func TestMonitorMetrics(t *testing.T) {
+	m := &monitor{size: 10}
+	a := clock.Sample{Server: "a", Response: &ntp.Response{ClockOffset: 5 * time.Millisecond}}
+	m.record(time.Unix(1700000000, 0), clock.Consensus{Offset: 5 * time.Millisecond, Truechimers: []clock.Sample{a}}, nil)
+	m.record(time.Unix(1700000064, 0), clock.Consensus{}, errors.New("timeout"))
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"ntp_last_success_timestamp_seconds 1700000000.000\n",
+		"ntp_sync_failures_total 1\n",
+		"ntp_sync_consecutive_failures 1\n",
+		"ntp_alert_level 2\n",
+		// после ошибки остается последний успешный результат
+		"ntp_offset_seconds 0.005\n",
+		"ntp_servers_agreed 1\n",
+		"# TYPE ntp_drift_ppm gauge\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics do not contain %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestNextDelay(t *testing.T) {
+	interval, maxInterval := time.Minute, 10*time.Minute
+	for i := 0; i < 100; i++ {
+		if d := nextDelay(interval, maxInterval, 0); d < 54*time.Second || d > 66*time.Second {
+			t.Fatalf("delay without failures out of range: %v", d)
+		}
+		if d := nextDelay(interval, maxInterval, 2); d < 2*time.Minute || d > 4*time.Minute {
+			t.Fatalf("delay after 2 failures out of range: %v", d)
+		}
+		if d := nextDelay(interval, maxInterval, 20); d < 5*time.Minute || d > maxInterval {
+			t.Fatalf("delay after many failures out of range: %v", d)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package nts
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// типы записей NTS-KE (RFC 8915, раздел 4)
+const (
+	recordEnd          = 0
+	recordNextProtocol = 1
+	recordError        = 2
+	recordWarning      = 3
+	recordAEAD         = 4
+	recordCookie       = 5
+	recordServer       = 6
+	recordPort         = 7
+
+	criticalBit = 0x8000
+)
+
+// параметры NTS-KE
+const (
+	// DefaultKEPort - порт NTS-KE по умолчанию.
+	DefaultKEPort = 4460
+
+	// ALPN - идентификатор протокола NTS-KE в TLS.
+	ALPN = "ntske/1"
+
+	protocolNTPv4     = 0
+	aeadAESSIVCMAC256 = 15
+	keySize           = 32
+	exporterLabel     = "EXPORTER-network-time-security"
+	maxKERecords      = 1024
+)
+
+// ошибки обмена ключами
+var (
+	ErrKEProtocol  = errors.New("malformed NTS-KE response")
+	ErrUnsupported = errors.New("NTS-KE server does not support NTPv4 with AEAD_AES_SIV_CMAC_256")
+	ErrNoCookies   = errors.New("NTS-KE server returned no cookies")
+)
+
+// KEError - ошибка, о которой сообщил сервер NTS-KE.
+type KEError struct {
+	Code uint16
+}
+
+func (e *KEError) Error() string {
+	switch e.Code {
+	case 0:
+		return "NTS-KE server error: unrecognized critical record"
+	case 1:
+		return "NTS-KE server error: bad request"
+	case 2:
+		return "NTS-KE server error: internal server error"
+	}
+	return fmt.Sprintf("NTS-KE server error %d", e.Code)
+}
+
+// Session - результат обмена ключами: адрес NTP-сервера, ключи AEAD
+// для запросов и ответов и запас cookie.
+type Session struct {
+	Server  string
+	c2s     []byte
+	s2c     []byte
+	cookies [][]byte
+}
+
+// Cookies возвращает число неиспользованных cookie.
+func (s *Session) Cookies() int {
+	return len(s.cookies)
+}
+
+// запись NTS-KE
+func appendRecord(buf []byte, typ uint16, critical bool, body []byte) []byte {
+	if critical {
+		typ |= criticalBit
+	}
+	buf = binary.BigEndian.AppendUint16(buf, typ)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(body)))
+	return append(buf, body...)
+}
+
+// запрос клиента: NTPv4, AEAD_AES_SIV_CMAC_256, конец сообщения
+func keRequest() []byte {
+	var req []byte
+	req = appendRecord(req, recordNextProtocol, true, binary.BigEndian.AppendUint16(nil, protocolNTPv4))
+	req = appendRecord(req, recordAEAD, true, binary.BigEndian.AppendUint16(nil, aeadAESSIVCMAC256))
+	return appendRecord(req, recordEnd, true, nil)
+}
+
+// ключ AEAD из TLS-экспортера: контекст - протокол, алгоритм и направление
+func exportKey(state tls.ConnectionState, direction byte) ([]byte, error) {
+	context := []byte{0, protocolNTPv4, 0, aeadAESSIVCMAC256, direction}
+	return state.ExportKeyingMaterial(exporterLabel, context, keySize)
+}
+
+// KeyExchange выполняет NTS-KE с сервером address (host или host:port)
+// поверх TLS 1.3 и возвращает сессию для защищенных запросов NTP.
+func KeyExchange(ctx context.Context, address string, config *tls.Config) (*Session, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		host, port = address, strconv.Itoa(DefaultKEPort)
+	}
+
+	cfg := &tls.Config{}
+	if config != nil {
+		cfg = config.Clone()
+	}
+	cfg.MinVersion = tls.VersionTLS13
+	cfg.NextProtos = []string{ALPN}
+	if cfg.ServerName == "" {
+		cfg.ServerName = host
+	}
+
+	dialer := &tls.Dialer{Config: cfg}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, fmt.Errorf("NTS-KE: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	tlsConn := conn.(*tls.Conn)
+	if tlsConn.ConnectionState().NegotiatedProtocol != ALPN {
+		return nil, fmt.Errorf("NTS-KE: server did not negotiate %s", ALPN)
+	}
+	if _, err := conn.Write(keRequest()); err != nil {
+		return nil, fmt.Errorf("NTS-KE: %w", err)
+	}
+
+	session := &Session{Server: host}
+	ntpPort := "123"
+	var protocolOK, aeadOK bool
+	for i := 0; ; i++ {
+		if i == maxKERecords {
+			return nil, ErrKEProtocol
+		}
+		var hdr [4]byte
+		if _, err := io.ReadFull(conn, hdr[:]); err != nil {
+			return nil, fmt.Errorf("NTS-KE: %w", err)
+		}
+		typ := binary.BigEndian.Uint16(hdr[:2]) &^ criticalBit
+		critical := binary.BigEndian.Uint16(hdr[:2])&criticalBit != 0
+		body := make([]byte, binary.BigEndian.Uint16(hdr[2:]))
+		if _, err := io.ReadFull(conn, body); err != nil {
+			return nil, fmt.Errorf("NTS-KE: %w", err)
+		}
+
+		switch typ {
+		case recordEnd:
+			if !protocolOK || !aeadOK {
+				return nil, ErrUnsupported
+			}
+			if len(session.cookies) == 0 {
+				return nil, ErrNoCookies
+			}
+			state := tlsConn.ConnectionState()
+			if session.c2s, err = exportKey(state, 0); err != nil {
+				return nil, err
+			}
+			if session.s2c, err = exportKey(state, 1); err != nil {
+				return nil, err
+			}
+			session.Server = net.JoinHostPort(session.Server, ntpPort)
+			return session, nil
+		case recordNextProtocol:
+			protocolOK = bytes.Equal(body, []byte{0, protocolNTPv4})
+		case recordAEAD:
+			aeadOK = bytes.Equal(body, []byte{0, aeadAESSIVCMAC256})
+		case recordError:
+			if len(body) != 2 {
+				return nil, ErrKEProtocol
+			}
+			return nil, &KEError{Code: binary.BigEndian.Uint16(body)}
+		case recordCookie:
+			session.cookies = append(session.cookies, body)
+		case recordServer:
+			session.Server = string(body)
+		case recordPort:
+			if len(body) != 2 {
+				return nil, ErrKEProtocol
+			}
+			ntpPort = strconv.Itoa(int(binary.BigEndian.Uint16(body)))
+		case recordWarning:
+			// предупреждения не мешают работе
+		default:
+			if critical {
+				return nil, fmt.Errorf("NTS-KE: unknown critical record %d: %w", typ, ErrKEProtocol)
+			}
+		}
+	}
+}
@@ -0,0 +1,273 @@
+// Package nts реализует клиент Network Time Security (RFC 8915): обмен
+// ключами NTS-KE поверх TLS 1.3 и запросы NTPv4, защищенные полями
+// расширения с проверкой подлинности AEAD_AES_SIV_CMAC_256.
+package nts
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+// типы полей расширения NTP для NTS
+const (
+	efUniqueID      = 0x0104
+	efCookie        = 0x0204
+	efPlaceholder   = 0x0304
+	efAuthenticator = 0x0404
+)
+
+// параметры защищенных запросов
+const (
+	headerSize = 48
+	uidSize    = 32
+	nonceSize  = 16
+
+	// MaxCookies - сколько cookie клиент старается держать в запасе.
+	MaxCookies = 8
+)
+
+// ошибки защищенных запросов
+var (
+	ErrAuth = errors.New("NTS response failed authentication")
+	ErrNAK  = errors.New("NTS server rejected the cookie (NTSN)")
+)
+
+// Client - клиент NTS. Ключи и cookie, полученные при обмене ключами,
+// используются в последующих запросах; каждый cookie отправляется один раз,
+// а новые приходят в зашифрованном виде в ответах сервера. Обмен ключами
+// повторяется, когда cookie закончились или сервер отверг cookie.
+type Client struct {
+	// Address - адрес сервера NTS-KE: host или host:port.
+	Address string
+
+	// TLSConfig задает, например, корневые сертификаты для сервера
+	// с самоподписанным сертификатом.
+	TLSConfig *tls.Config
+
+	// Timeout ограничивает обмен ключами и запрос; по умолчанию 5 секунд.
+	Timeout time.Duration
+
+	mu      sync.Mutex
+	session *Session
+}
+
+// Query выполняет защищенный запрос NTP и возвращает проверенный ответ.
+func (c *Client) Query(ctx context.Context) (*ntp.Response, error) {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.session == nil || len(c.session.cookies) == 0 {
+		session, err := KeyExchange(ctx, c.Address, c.TLSConfig)
+		if err != nil {
+			return nil, err
+		}
+		c.session = session
+	}
+
+	deadline, _ := ctx.Deadline()
+	ext, err := newExchange(c.session)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := ntp.QueryWithOptions(c.session.Server, ntp.QueryOptions{
+		Timeout:    time.Until(deadline),
+		Extensions: []ntp.Extension{ext},
+	})
+	if errors.Is(err, ErrNAK) {
+		//сервер не принимает наши cookie: при следующем запросе нужен новый обмен ключами
+		c.session = nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("NTS query to %s: %w", ext.server, err)
+	}
+	return resp, nil
+}
+
+// Cookies возвращает число cookie в запасе.
+func (c *Client) Cookies() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session == nil {
+		return 0
+	}
+	return c.session.Cookies()
+}
+
+// exchange - поля расширения одного запроса, реализует ntp.Extension
+type exchange struct {
+	session *Session
+	server  string
+	aead    *aesSIV // ключ ответов
+	uid     []byte
+	cookie  []byte
+}
+
+// подготовка запроса: cookie извлекается из запаса, чтобы не отправить его дважды
+func newExchange(s *Session) (*exchange, error) {
+	aead, err := newAESSIV(s.s2c)
+	if err != nil {
+		return nil, err
+	}
+	uid := make([]byte, uidSize)
+	if _, err := rand.Read(uid); err != nil {
+		return nil, err
+	}
+	cookie := s.cookies[0]
+	s.cookies = s.cookies[1:]
+	return &exchange{session: s, server: s.Server, aead: aead, uid: uid, cookie: cookie}, nil
+}
+
+// ProcessQuery дописывает идентификатор, cookie, заполнители для новых cookie
+// и поле проверки подлинности.
+func (e *exchange) ProcessQuery(buf *bytes.Buffer) error {
+	buf.Write(appendField(nil, efUniqueID, e.uid))
+	buf.Write(appendField(nil, efCookie, e.cookie))
+	placeholder := make([]byte, len(e.cookie))
+	for i := len(e.session.cookies) + 1; i < MaxCookies; i++ {
+		buf.Write(appendField(nil, efPlaceholder, placeholder))
+	}
+
+	aead, err := newAESSIV(e.session.c2s)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	buf.Write(appendField(nil, efAuthenticator, authenticator(aead, buf.Bytes(), nonce, nil)))
+	return nil
+}
+
+// ProcessResponse проверяет идентификатор и подлинность ответа и забирает
+// новые cookie из зашифрованных полей.
+func (e *exchange) ProcessResponse(buf []byte) error {
+	if len(buf) < headerSize {
+		return ErrAuth
+	}
+	fields, err := parseFields(buf[headerSize:])
+	if err != nil {
+		return err
+	}
+
+	var uidOK bool
+	for _, f := range fields {
+		switch f.typ {
+		case efUniqueID:
+			uidOK = bytes.Equal(f.body, e.uid)
+		case efAuthenticator:
+			if !uidOK {
+				return ErrAuth
+			}
+			nonce, ciphertext, err := parseAuthenticator(f.body)
+			if err != nil {
+				return err
+			}
+			plaintext, err := e.aead.open([][]byte{buf[:headerSize+f.offset], nonce}, ciphertext)
+			if err != nil {
+				return ErrAuth
+			}
+			encrypted, err := parseFields(plaintext)
+			if err != nil {
+				return err
+			}
+			for _, ef := range encrypted {
+				if ef.typ == efCookie {
+					e.session.cookies = append(e.session.cookies, ef.body)
+				}
+			}
+			return nil
+		}
+	}
+	//kiss-o'-death NTSN не зашифрован, но должен повторять наш идентификатор
+	if uidOK && isNAK(buf) {
+		return ErrNAK
+	}
+	return ErrAuth
+}
+
+// ответ kiss-o'-death с кодом NTSN
+func isNAK(buf []byte) bool {
+	return buf[1] == 0 && string(buf[12:16]) == "NTSN"
+}
+
+// field - поле расширения NTP; offset отсчитывается от начала списка полей
+type field struct {
+	typ    uint16
+	body   []byte
+	offset int
+}
+
+// поле расширения с выравниванием до 4 байт, не короче 16 байт
+func appendField(buf []byte, typ uint16, body []byte) []byte {
+	length := 4 + len(body)
+	length += (4 - length%4) % 4
+	if length < 16 {
+		length = 16
+	}
+	buf = binary.BigEndian.AppendUint16(buf, typ)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(length))
+	buf = append(buf, body...)
+	return append(buf, make([]byte, length-4-len(body))...)
+}
+
+// разбор последовательности полей расширения
+func parseFields(buf []byte) ([]field, error) {
+	var fields []field
+	for off := 0; off < len(buf); {
+		if len(buf)-off < 4 {
+			return nil, ErrAuth
+		}
+		length := int(binary.BigEndian.Uint16(buf[off+2:]))
+		if length < 4 || length%4 != 0 || off+length > len(buf) {
+			return nil, ErrAuth
+		}
+		fields = append(fields, field{
+			typ:    binary.BigEndian.Uint16(buf[off:]),
+			body:   buf[off+4 : off+length],
+			offset: off,
+		})
+		off += length
+	}
+	return fields, nil
+}
+
+// тело поля проверки подлинности: длины nonce и шифртекста, затем они сами
+// с выравниванием до 4 байт; ad - пакет до этого поля
+func authenticator(aead *aesSIV, ad, nonce, plaintext []byte) []byte {
+	ciphertext := aead.seal([][]byte{ad, nonce}, plaintext)
+	body := binary.BigEndian.AppendUint16(nil, uint16(len(nonce)))
+	body = binary.BigEndian.AppendUint16(body, uint16(len(ciphertext)))
+	body = append(body, nonce...)
+	body = append(body, make([]byte, (4-len(nonce)%4)%4)...)
+	return append(body, ciphertext...)
+}
+
+func parseAuthenticator(body []byte) (nonce, ciphertext []byte, err error) {
+	if len(body) < 4 {
+		return nil, nil, ErrAuth
+	}
+	nonceLen := int(binary.BigEndian.Uint16(body))
+	ctLen := int(binary.BigEndian.Uint16(body[2:]))
+	paddedNonce := nonceLen + (4-nonceLen%4)%4
+	if nonceLen < nonceSize || 4+paddedNonce+ctLen > len(body) {
+		return nil, nil, ErrAuth
+	}
+	return body[4 : 4+nonceLen], body[4+paddedNonce : 4+paddedNonce+ctLen], nil
+}
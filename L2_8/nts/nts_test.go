@@ -0,0 +1,310 @@
+package nts
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// standIn - локальные серверы NTS-KE и NTP с самоподписанным сертификатом.
+// Вместо шифрования ключей в cookie сервер хранит таблицу cookie -> ключи.
+type standIn struct {
+	keAddr string
+	roots  *x509.CertPool
+	offset time.Duration
+	port   int
+
+	mu        sync.Mutex
+	keys      map[string][2][]byte
+	exchanges int
+	tamper    bool
+	nak       bool
+}
+
+func startStandIn(t *testing.T, offset time.Duration) *standIn {
+	t.Helper()
+	s := &standIn{offset: offset, keys: map[string][2][]byte{}}
+
+	//самоподписанный сертификат для 127.0.0.1
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "nts stand-in"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	s.roots = x509.NewCertPool()
+	s.roots.AddCert(cert)
+
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { udp.Close() })
+	s.port = udp.LocalAddr().(*net.UDPAddr).Port
+	go s.serveNTP(udp)
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: priv}},
+		NextProtos:   []string{ALPN},
+		MinVersion:   tls.VersionTLS13,
+	})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s.keAddr = ln.Addr().String()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serveKE(conn.(*tls.Conn))
+		}
+	}()
+	return s
+}
+
+func (s *standIn) client() *Client {
+	return &Client{Address: s.keAddr, TLSConfig: &tls.Config{RootCAs: s.roots}, Timeout: 2 * time.Second}
+}
+
+func (s *standIn) newCookie(keys [2][]byte) []byte {
+	cookie := make([]byte, 64)
+	rand.Read(cookie)
+	s.keys[string(cookie)] = keys
+	return cookie
+}
+
+func (s *standIn) serveKE(conn *tls.Conn) {
+	defer conn.Close()
+	for {
+		var hdr [4]byte
+		if _, err := io.ReadFull(conn, hdr[:]); err != nil {
+			return
+		}
+		body := make([]byte, binary.BigEndian.Uint16(hdr[2:]))
+		if _, err := io.ReadFull(conn, body); err != nil {
+			return
+		}
+		if binary.BigEndian.Uint16(hdr[:2])&^criticalBit == recordEnd {
+			break
+		}
+	}
+
+	state := conn.ConnectionState()
+	c2s, _ := exportKey(state, 0)
+	s2c, _ := exportKey(state, 1)
+
+	s.mu.Lock()
+	s.exchanges++
+	resp := appendRecord(nil, recordNextProtocol, true, []byte{0, protocolNTPv4})
+	resp = appendRecord(resp, recordAEAD, false, []byte{0, aeadAESSIVCMAC256})
+	resp = appendRecord(resp, recordServer, false, []byte("127.0.0.1"))
+	resp = appendRecord(resp, recordPort, false, binary.BigEndian.AppendUint16(nil, uint16(s.port)))
+	for i := 0; i < MaxCookies; i++ {
+		resp = appendRecord(resp, recordCookie, false, s.newCookie([2][]byte{c2s, s2c}))
+	}
+	s.mu.Unlock()
+	conn.Write(appendRecord(resp, recordEnd, true, nil))
+}
+
+func ntpTime(t time.Time) []byte {
+	const unixToNTP = 2208988800
+	sec := uint64(t.Unix() + unixToNTP)
+	frac := uint64(t.Nanosecond()) << 32 / 1e9
+	return binary.BigEndian.AppendUint64(nil, sec<<32|frac)
+}
+
+func (s *standIn) serveNTP(conn net.PacketConn) {
+	buf := make([]byte, 2048)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if resp := s.response(buf[:n]); resp != nil {
+			conn.WriteTo(resp, addr)
+		}
+	}
+}
+
+func (s *standIn) response(req []byte) []byte {
+	if len(req) < headerSize {
+		return nil
+	}
+	fields, err := parseFields(req[headerSize:])
+	if err != nil {
+		return nil
+	}
+	var uid, cookie []byte
+	placeholders := 0
+	auth := -1
+	for i, f := range fields {
+		switch f.typ {
+		case efUniqueID:
+			uid = f.body
+		case efCookie:
+			cookie = f.body
+		case efPlaceholder:
+			placeholders++
+		case efAuthenticator:
+			auth = i
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys, ok := s.keys[string(cookie)]
+	delete(s.keys, string(cookie))
+
+	now := time.Now().Add(s.offset)
+	resp := []byte{0x24, 1, 0, 0xec}
+	resp = append(resp, make([]byte, 8)...)
+	resp = append(resp, "TEST"...)
+	resp = append(resp, ntpTime(now.Add(-time.Minute))...)
+	resp = append(resp, req[40:48]...)
+	resp = append(resp, ntpTime(now)...)
+	resp = append(resp, ntpTime(now)...)
+
+	if !ok || s.nak || auth < 0 {
+		//kiss-o'-death NTSN без проверки подлинности
+		resp[1] = 0
+		copy(resp[12:16], "NTSN")
+		return appendField(resp, efUniqueID, uid)
+	}
+	c2s, _ := newAESSIV(keys[0])
+	nonce, ciphertext, err := parseAuthenticator(fields[auth].body)
+	if err != nil {
+		return nil
+	}
+	if _, err := c2s.open([][]byte{req[:headerSize+fields[auth].offset], nonce}, ciphertext); err != nil {
+		return nil
+	}
+
+	var plaintext []byte
+	for i := 0; i <= placeholders; i++ {
+		plaintext = appendField(plaintext, efCookie, s.newCookie(keys))
+	}
+	resp = appendField(resp, efUniqueID, uid)
+	s2c, _ := newAESSIV(keys[1])
+	respNonce := make([]byte, nonceSize)
+	rand.Read(respNonce)
+	resp = appendField(resp, efAuthenticator, authenticator(s2c, resp, respNonce, plaintext))
+	if s.tamper {
+		resp[47] ^= 1
+	}
+	return resp
+}
+
+func (s *standIn) set(tamper, nak bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tamper, s.nak = tamper, nak
+}
+
+func TestQuery(t *testing.T) {
+	s := startStandIn(t, 3*time.Second)
+	c := s.client()
+
+	resp, err := c.Query(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := resp.Validate(); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if d := resp.ClockOffset - 3*time.Second; d < -100*time.Millisecond || d > 100*time.Millisecond {
+		t.Errorf("offset = %v, want about 3s", resp.ClockOffset)
+	}
+	if c.Cookies() != MaxCookies {
+		t.Errorf("cookies = %d, want %d", c.Cookies(), MaxCookies)
+	}
+}
+
+func TestCookieRotation(t *testing.T) {
+	s := startStandIn(t, 0)
+	c := s.client()
+
+	//каждый cookie используется один раз, поэтому без обновления запаса
+	//после восьми запросов понадобился бы новый обмен ключами
+	for i := 0; i < 3*MaxCookies; i++ {
+		if _, err := c.Query(context.Background()); err != nil {
+			t.Fatalf("query %d: %v", i, err)
+		}
+	}
+	if s.exchanges != 1 {
+		t.Errorf("key exchanges = %d, want 1", s.exchanges)
+	}
+	if c.Cookies() != MaxCookies {
+		t.Errorf("cookies = %d, want %d", c.Cookies(), MaxCookies)
+	}
+}
+
+func TestTamperedResponse(t *testing.T) {
+	s := startStandIn(t, 0)
+	s.set(true, false)
+
+	_, err := s.client().Query(context.Background())
+	if !errors.Is(err, ErrAuth) {
+		t.Fatalf("expected ErrAuth, got %v", err)
+	}
+}
+
+func TestNAK(t *testing.T) {
+	s := startStandIn(t, 0)
+	c := s.client()
+	if _, err := c.Query(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	s.set(false, true)
+	if _, err := c.Query(context.Background()); !errors.Is(err, ErrNAK) {
+		t.Fatalf("expected ErrNAK, got %v", err)
+	}
+
+	//после отказа клиент заново получает ключи
+	s.set(false, false)
+	if _, err := c.Query(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.exchanges != 2 {
+		t.Errorf("key exchanges = %d, want 2", s.exchanges)
+	}
+}
+
+func TestUntrustedCertificate(t *testing.T) {
+	s := startStandIn(t, 0)
+	c := &Client{Address: s.keAddr, Timeout: 2 * time.Second}
+
+	var certErr *tls.CertificateVerificationError
+	if _, err := c.Query(context.Background()); !errors.As(err, &certErr) {
+		t.Fatalf("expected certificate verification error, got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package nts
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+)
+
+// errOpen возвращается, если шифртекст не прошел проверку подлинности
+var errOpen = errors.New("message authentication failed")
+
+// cmac вычисляет AES-CMAC (RFC 4493)
+type cmac struct {
+	block  cipher.Block
+	k1, k2 [aes.BlockSize]byte
+}
+
+// удвоение в GF(2^128)
+func dbl(b [aes.BlockSize]byte) [aes.BlockSize]byte {
+	var out [aes.BlockSize]byte
+	carry := b[0] >> 7
+	for i := 0; i < aes.BlockSize-1; i++ {
+		out[i] = b[i]<<1 | b[i+1]>>7
+	}
+	out[aes.BlockSize-1] = b[aes.BlockSize-1] << 1
+	if carry != 0 {
+		out[aes.BlockSize-1] ^= 0x87
+	}
+	return out
+}
+
+func newCMAC(key []byte) (*cmac, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	c := &cmac{block: block}
+	var l [aes.BlockSize]byte
+	block.Encrypt(l[:], l[:])
+	c.k1 = dbl(l)
+	c.k2 = dbl(c.k1)
+	return c, nil
+}
+
+func (c *cmac) sum(msg []byte) [aes.BlockSize]byte {
+	var x [aes.BlockSize]byte
+	n := (len(msg) + aes.BlockSize - 1) / aes.BlockSize
+	if n == 0 {
+		n = 1
+	}
+	for i := 0; i < n-1; i++ {
+		subtle.XORBytes(x[:], x[:], msg[i*aes.BlockSize:(i+1)*aes.BlockSize])
+		c.block.Encrypt(x[:], x[:])
+	}
+
+	// последний блок: полный - с ключом k1, неполный - дополненный и с ключом k2
+	var last [aes.BlockSize]byte
+	rest := msg[(n-1)*aes.BlockSize:]
+	copy(last[:], rest)
+	if len(rest) == aes.BlockSize {
+		subtle.XORBytes(last[:], last[:], c.k1[:])
+	} else {
+		last[len(rest)] = 0x80
+		subtle.XORBytes(last[:], last[:], c.k2[:])
+	}
+	subtle.XORBytes(x[:], x[:], last[:])
+	c.block.Encrypt(x[:], x[:])
+	return x
+}
+
+// aesSIV - AEAD_AES_SIV_CMAC_256 (RFC 5297): первая половина ключа для S2V,
+// вторая - для шифрования в режиме CTR
+type aesSIV struct {
+	mac *cmac
+	ctr cipher.Block
+}
+
+func newAESSIV(key []byte) (*aesSIV, error) {
+	if len(key) != 32 {
+		return nil, aes.KeySizeError(len(key))
+	}
+	mac, err := newCMAC(key[:16])
+	if err != nil {
+		return nil, err
+	}
+	ctr, err := aes.NewCipher(key[16:])
+	if err != nil {
+		return nil, err
+	}
+	return &aesSIV{mac: mac, ctr: ctr}, nil
+}
+
+// s2v сворачивает вектор строк (ассоциированные данные и открытый текст) в IV
+func (s *aesSIV) s2v(ad [][]byte, plaintext []byte) [aes.BlockSize]byte {
+	var zero [aes.BlockSize]byte
+	d := s.mac.sum(zero[:])
+	for _, a := range ad {
+		m := s.mac.sum(a)
+		d = dbl(d)
+		subtle.XORBytes(d[:], d[:], m[:])
+	}
+
+	var t []byte
+	if len(plaintext) >= aes.BlockSize {
+		t = append([]byte(nil), plaintext...)
+		tail := t[len(t)-aes.BlockSize:]
+		subtle.XORBytes(tail, tail, d[:])
+	} else {
+		var padded [aes.BlockSize]byte
+		copy(padded[:], plaintext)
+		padded[len(plaintext)] = 0x80
+		d = dbl(d)
+		subtle.XORBytes(padded[:], padded[:], d[:])
+		t = padded[:]
+	}
+	return s.mac.sum(t)
+}
+
+// шифрование в режиме CTR со счетчиком из IV с обнуленными битами 31 и 63
+func (s *aesSIV) xorCTR(iv [aes.BlockSize]byte, dst, src []byte) {
+	q := iv
+	q[8] &= 0x7f
+	q[12] &= 0x7f
+	cipher.NewCTR(s.ctr, q[:]).XORKeyStream(dst, src)
+}
+
+// seal возвращает IV, за которым следует шифртекст
+func (s *aesSIV) seal(ad [][]byte, plaintext []byte) []byte {
+	iv := s.s2v(ad, plaintext)
+	out := make([]byte, aes.BlockSize+len(plaintext))
+	copy(out, iv[:])
+	s.xorCTR(iv, out[aes.BlockSize:], plaintext)
+	return out
+}
+
+// open расшифровывает и проверяет результат seal
+func (s *aesSIV) open(ad [][]byte, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < aes.BlockSize {
+		return nil, errOpen
+	}
+	var iv [aes.BlockSize]byte
+	copy(iv[:], ciphertext)
+	plaintext := make([]byte, len(ciphertext)-aes.BlockSize)
+	s.xorCTR(iv, plaintext, ciphertext[aes.BlockSize:])
+
+	expected := s.s2v(ad, plaintext)
+	if subtle.ConstantTimeCompare(expected[:], iv[:]) != 1 {
+		return nil, errOpen
+	}
+	return plaintext, nil
+}
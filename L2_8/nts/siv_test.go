@@ -0,0 +1,56 @@
+package nts
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestAESSIV(t *testing.T) {
+	// RFC 5297, приложение A.1
+	key := unhex("fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
+	ad := unhex("101112131415161718191a1b1c1d1e1f2021222324252627")
+	plaintext := unhex("112233445566778899aabbccddee")
+	expect := unhex("85632d07c6e8f37f950acd320a2ecc9340c02b9690c4dc04daef7f6afe5c")
+
+	siv, err := newAESSIV(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sealed := siv.seal([][]byte{ad}, plaintext)
+	if !bytes.Equal(sealed, expect) {
+		t.Fatalf("got %x, want %x", sealed, expect)
+	}
+
+	opened, err := siv.open([][]byte{ad}, sealed)
+	if err != nil || !bytes.Equal(opened, plaintext) {
+		t.Errorf("open: got %x (%v), want %x", opened, err, plaintext)
+	}
+
+	sealed[len(sealed)-1] ^= 1
+	if _, err := siv.open([][]byte{ad}, sealed); err == nil {
+		t.Error("expected error for tampered ciphertext")
+	}
+}
+
+func TestCMAC(t *testing.T) {
+	// RFC 4493, примеры 1 и 2
+	mac, _ := newCMAC(unhex("2b7e151628aed2a6abf7158809cf4f3c"))
+	tests := []struct{ msg, tag string }{
+		{"", "bb1d6929e95937287fa37d129b756746"},
+		{"6bc1bee22e409f96e93d7e117393172a", "070a16b46b4d4144f79bdd9dd04a287c"},
+	}
+	for _, tt := range tests {
+		if got := mac.sum(unhex(tt.msg)); hex.EncodeToString(got[:]) != tt.tag {
+			t.Errorf("cmac(%q): got %x, want %s", tt.msg, got, tt.tag)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"time"
+
+	"WB_L2/L2_8/clock"
+	"github.com/beevik/ntp"
+)
+
+// queryFlags - общие для всех режимов флаги опроса серверов
+type queryFlags struct {
+	servers *string
+	timeout *time.Duration
+	keys    *string
+	keyIDs  *string
+}
+
+// регистрация флагов опроса в наборе fs
+func addQueryFlags(fs *flag.FlagSet, name, servers, usage string) *queryFlags {
+	return &queryFlags{
+		servers: fs.String(name, servers, usage),
+		timeout: fs.Duration("timeout", 5*time.Second, "query timeout for each server"),
+		keys:    fs.String("keys", "", "ntp.keys-style file with symmetric authentication keys"),
+		keyIDs:  fs.String("keyid", "", "authentication key id for all servers and/or server=id pairs, comma-separated"),
+	}
+}
+
+// queryParams - разобранные параметры опроса
+type queryParams struct {
+	servers []string
+	opt     ntp.QueryOptions
+	auth    *clock.Auth
+}
+
+// разбор флагов опроса, список серверов может оказаться пустым
+func (q *queryFlags) parse() (queryParams, error) {
+	p := queryParams{
+		servers: clock.SplitServers(*q.servers),
+		opt:     ntp.QueryOptions{Timeout: *q.timeout},
+	}
+	if *q.keys == "" && *q.keyIDs == "" {
+		return p, nil
+	}
+
+	p.auth = &clock.Auth{}
+	if *q.keys != "" {
+		keys, err := clock.LoadKeys(*q.keys)
+		if err != nil {
+			return p, err
+		}
+		p.auth.Keys = keys
+	}
+	if err := p.auth.ParseKeyIDs(*q.keyIDs); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// опрос серверов и выбор согласованного времени
+func (p queryParams) run() (clock.Consensus, error) {
+	return clock.SelectConsensus(clock.QueryAll(p.servers, p.opt, p.auth))
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"WB_L2/L2_8/clock"
+	"WB_L2/L2_8/source"
+	"github.com/beevik/ntp"
+)
+
+// serverReport - диагностика ответа одного сервера, длительности в секундах
+type serverReport struct {
+	Server         string  `json:"server"`
+	Status         string  `json:"status"` // truechimer, falseticker или failed
+	Error          string  `json:"error,omitempty"`
+	Offset         float64 `json:"offset"`
+	RTT            float64 `json:"rtt"`
+	Stratum        uint8   `json:"stratum"`
+	ReferenceID    string  `json:"reference_id"`
+	ReferenceTime  string  `json:"reference_time,omitempty"`
+	RootDelay      float64 `json:"root_delay"`
+	RootDispersion float64 `json:"root_dispersion"`
+	RootDistance   float64 `json:"root_distance"`
+	Leap           string  `json:"leap"`
+	Precision      float64 `json:"precision"`
+	Poll           float64 `json:"poll"`
+	Version        int     `json:"version"`
+
+	responded bool
+}
+
+// report - полный диагностический отчет
+type report struct {
+	Source   string         `json:"source,omitempty"`
+	Time     string         `json:"time,omitempty"`
+	Zone     string         `json:"zone,omitempty"`
+	UnixNano int64          `json:"unix_nano,omitempty"`
+	Offset   float64        `json:"offset"`
+	RTT      float64        `json:"rtt"`
+	Low      float64        `json:"low"`
+	High     float64        `json:"high"`
+	Error    string         `json:"error,omitempty"`
+	Servers  []serverReport `json:"servers"`
+}
+
+// названия индикатора коррекции секунды
+func leapString(l ntp.LeapIndicator) string {
+	switch l {
+	case ntp.LeapNoWarning:
+		return "none"
+	case ntp.LeapAddSecond:
+		return "add second"
+	case ntp.LeapDelSecond:
+		return "delete second"
+	default:
+		return "not in sync"
+	}
+}
+
+func newServerReport(s clock.Sample, status string) serverReport {
+	r := serverReport{Server: s.Server, Status: status}
+	if s.Err != nil {
+		r.Error = s.Err.Error()
+	}
+	// при ошибке запроса ответа нет, при ошибке Validate ответ есть
+	resp := s.Response
+	if resp == nil {
+		return r
+	}
+	r.responded = true
+	r.Offset = resp.ClockOffset.Seconds()
+	r.RTT = resp.RTT.Seconds()
+	r.Stratum = resp.Stratum
+	r.ReferenceID = resp.ReferenceString()
+	if !resp.ReferenceTime.IsZero() {
+		r.ReferenceTime = resp.ReferenceTime.UTC().Format(time.RFC3339Nano)
+	}
+	r.RootDelay = resp.RootDelay.Seconds()
+	r.RootDispersion = resp.RootDispersion.Seconds()
+	r.RootDistance = resp.RootDistance.Seconds()
+	r.Leap = leapString(resp.Leap)
+	r.Precision = resp.Precision.Seconds()
+	r.Poll = resp.Poll.Seconds()
+	r.Version = resp.Version
+	return r
+}
+
+// сборка отчета по результату измерения времени
+func newReport(now time.Time, m source.Measurement, err error, tf timeFormat) report {
+	r := report{
+		Source: m.Source,
+		Offset: m.Offset.Seconds(),
+		RTT:    m.RTT.Seconds(),
+		Low:    (m.Offset - m.Uncertainty).Seconds(),
+		High:   (m.Offset + m.Uncertainty).Seconds(),
+	}
+	if err != nil {
+		r.Error = err.Error()
+	} else {
+		t := now.Add(m.Offset)
+		r.Time = tf.format(t)
+		r.Zone = tf.loc.String()
+		r.UnixNano = t.UnixNano()
+	}
+
+	res := m.Consensus
+	if res == nil {
+		return r
+	}
+	r.Low, r.High = res.Low.Seconds(), res.High.Seconds()
+	for _, s := range res.Truechimers {
+		r.Servers = append(r.Servers, newServerReport(s, "truechimer"))
+	}
+	for _, s := range res.Falsetickers {
+		r.Servers = append(r.Servers, newServerReport(s, "falseticker"))
+	}
+	for _, s := range res.Failed {
+		r.Servers = append(r.Servers, newServerReport(s, "failed"))
+	}
+	return r
+}
+
+// вывод отчета в формате JSON
+func writeReportJSON(w io.Writer, r report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// вывод отчета в текстовом виде
+func writeReportText(w io.Writer, r report) {
+	sec := func(v float64) time.Duration {
+		return time.Duration(v * float64(time.Second))
+	}
+
+	if r.Error != "" {
+		fmt.Fprintf(w, "error:           %s\n", r.Error)
+	} else {
+		fmt.Fprintf(w, "source:          %s\n", r.Source)
+		fmt.Fprintf(w, "time:            %s\n", r.Time)
+		fmt.Fprintf(w, "zone:            %s\n", r.Zone)
+		fmt.Fprintf(w, "offset:          %v\n", sec(r.Offset))
+		fmt.Fprintf(w, "rtt:             %v\n", sec(r.RTT))
+		fmt.Fprintf(w, "interval:        [%v, %v]\n", sec(r.Low), sec(r.High))
+	}
+	for _, s := range r.Servers {
+		fmt.Fprintf(w, "\nserver %s (%s)\n", s.Server, s.Status)
+		if s.Error != "" {
+			fmt.Fprintf(w, "  error:           %s\n", s.Error)
+		}
+		if !s.responded {
+			// ответа не было, выводить нечего
+			continue
+		}
+		fmt.Fprintf(w, "  offset:          %v\n", sec(s.Offset))
+		fmt.Fprintf(w, "  rtt:             %v\n", sec(s.RTT))
+		fmt.Fprintf(w, "  stratum:         %d\n", s.Stratum)
+		fmt.Fprintf(w, "  reference id:    %s\n", s.ReferenceID)
+		fmt.Fprintf(w, "  reference time:  %s\n", s.ReferenceTime)
+		fmt.Fprintf(w, "  root delay:      %v\n", sec(s.RootDelay))
+		fmt.Fprintf(w, "  root dispersion: %v\n", sec(s.RootDispersion))
+		fmt.Fprintf(w, "  root distance:   %v\n", sec(s.RootDistance))
+		fmt.Fprintf(w, "  leap:            %s\n", s.Leap)
+		fmt.Fprintf(w, "  precision:       %v\n", sec(s.Precision))
+		fmt.Fprintf(w, "  poll:            %v\n", sec(s.Poll))
+		fmt.Fprintf(w, "  version:         %d\n", s.Version)
+	}
+}
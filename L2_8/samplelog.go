@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"WB_L2/L2_8/clock"
+	"WB_L2/L2_8/source"
+)
+
+// logRecord - одно измерение в журнале JSON Lines, длительности в секундах
+type logRecord struct {
+	Timestamp time.Time `json:"timestamp"`
+	Server    string    `json:"server"`
+	Offset    float64   `json:"offset"`
+	Delay     float64   `json:"delay"`
+	Stratum   uint8     `json:"stratum,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// записи по каждому опрошенному NTP-серверу
+func consensusRecords(now time.Time, res clock.Consensus) []logRecord {
+	var records []logRecord
+	for _, list := range [][]clock.Sample{res.Truechimers, res.Falsetickers, res.Failed} {
+		for _, s := range list {
+			r := logRecord{Timestamp: now, Server: s.Server}
+			if s.Response != nil {
+				r.Offset = s.Response.ClockOffset.Seconds()
+				r.Delay = s.Response.RTT.Seconds()
+				r.Stratum = s.Response.Stratum
+			}
+			if s.Err != nil {
+				r.Error = s.Err.Error()
+			}
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// записи по результату измерения: для NTP - по серверам, иначе одна запись источника
+func measurementRecords(now time.Time, m source.Measurement, err error) []logRecord {
+	if m.Consensus != nil {
+		return consensusRecords(now, *m.Consensus)
+	}
+	r := logRecord{Timestamp: now, Server: m.Source, Offset: m.Offset.Seconds(), Delay: m.RTT.Seconds()}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return []logRecord{r}
+}
+
+// дописывание записей в журнал
+func appendLog(path string, records []logRecord) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(file)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	return file.Close()
+}
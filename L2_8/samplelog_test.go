@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"WB_L2/L2_8/clock"
+	"WB_L2/L2_8/source"
+	"github.com/beevik/ntp"
+)
+
+func TestLogRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "samples.jsonl")
+	first := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	second := first.Add(time.Minute)
+	third := second.Add(time.Minute)
+
+	// опрос NTP: честный сервер, лжец и сервер, не ответивший вовсе
+	res := clock.Consensus{
+		Truechimers:  []clock.Sample{{Server: "a", Response: &ntp.Response{ClockOffset: 5 * time.Millisecond, RTT: 20 * time.Millisecond, Stratum: 2}}},
+		Falsetickers: []clock.Sample{{Server: "b", Response: &ntp.Response{ClockOffset: time.Minute, RTT: 10 * time.Millisecond, Stratum: 1}}},
+		Failed:       []clock.Sample{{Server: "c", Err: errors.New("i/o timeout")}},
+	}
+	// резервный источник: удачное измерение и ошибка
+	ok := source.Measurement{Source: "http", Offset: -250 * time.Millisecond, RTT: 500 * time.Millisecond}
+	failed := source.Measurement{Source: "fallback"}
+
+	for _, records := range [][]logRecord{
+		measurementRecords(first, source.Measurement{Source: "ntp", Consensus: &res}, nil),
+		measurementRecords(second, ok, nil),
+		measurementRecords(third, failed, errors.New("all time sources failed")),
+	} {
+		if err := appendLog(path, records); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records, err := readLog(file)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	expect := []logRecord{
+		{Timestamp: first, Server: "a", Offset: 0.005, Delay: 0.02, Stratum: 2},
+		{Timestamp: first, Server: "b", Offset: 60, Delay: 0.01, Stratum: 1},
+		{Timestamp: first, Server: "c", Error: "i/o timeout"},
+		{Timestamp: second, Server: "http", Offset: -0.25, Delay: 0.5},
+		{Timestamp: third, Server: "fallback", Error: "all time sources failed"},
+	}
+	if !reflect.DeepEqual(records, expect) {
+		t.Errorf("got records\n%+v\nwant\n%+v", records, expect)
+	}
+}
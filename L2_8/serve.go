@@ -0,0 +1,256 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"log"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+// размер заголовка NTP-пакета
+const ntpPacketSize = 48
+
+// режимы NTP (RFC 5905)
+const (
+	modeClient = 3
+	modeServer = 4
+)
+
+// начало эпохи NTP
+var ntpEpoch = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var errShortPacket = errors.New("packet is too short")
+
+// reference - сведения об источнике времени сервера
+type reference struct {
+	stratum        uint8
+	id             uint32
+	time           time.Time
+	rootDelay      time.Duration
+	rootDispersion time.Duration
+	leap           ntp.LeapIndicator
+}
+
+// sntpServer - SNTP-сервер (RFC 4330), отвечающий по локальным часам
+// или по часам, подстроенным под вышестоящие серверы
+type sntpServer struct {
+	conn   net.PacketConn
+	maxAge time.Duration // после этого без подстройки часы объявляются несинхронизированными
+
+	mu     sync.RWMutex
+	offset time.Duration // поправка к локальным часам
+	ref    reference
+}
+
+// текущее время сервера с учетом поправки
+func (s *sntpServer) now() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return time.Now().Add(s.offset)
+}
+
+// обновление поправки и сведений об источнике времени
+func (s *sntpServer) setReference(offset time.Duration, ref reference) {
+	s.mu.Lock()
+	s.offset, s.ref = offset, ref
+	s.mu.Unlock()
+}
+
+// цикл обработки запросов, завершается при закрытии соединения
+func (s *sntpServer) serve() error {
+	buf := make([]byte, 1024)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		recv := s.now()
+
+		resp, err := s.response(buf[:n], recv)
+		if err != nil {
+			// на некорректные запросы не отвечаем
+			continue
+		}
+		if _, err := s.conn.WriteTo(resp, addr); err != nil {
+			log.Printf("sntp: writing to %v: %v", addr, err)
+		}
+	}
+}
+
+// формирование ответа на запрос клиента
+func (s *sntpServer) response(req []byte, recv time.Time) ([]byte, error) {
+	if len(req) < ntpPacketSize {
+		return nil, errShortPacket
+	}
+	version := (req[0] >> 3) & 0x7
+	mode := req[0] & 0x7
+	if mode != modeClient || version < 1 || version > 4 {
+		return nil, ntp.ErrInvalidMode
+	}
+
+	s.mu.RLock()
+	ref := s.ref
+	s.mu.RUnlock()
+	if ref.time.IsZero() {
+		// локальные часы сами себе источник
+		ref.time = recv
+	} else if s.maxAge > 0 && recv.Sub(ref.time) > s.maxAge {
+		// вышестоящие серверы давно недоступны: LI=3, клиенты не должны
+		// доверять нашему времени (RFC 4330)
+		ref.leap = ntp.LeapNotInSync
+	}
+
+	resp := make([]byte, ntpPacketSize)
+	resp[0] = byte(ref.leap)<<6 | version<<3 | modeServer
+	resp[1] = ref.stratum
+	resp[2] = req[2]          // poll копируем из запроса
+	resp[3] = byte(precision) // точность локальных часов
+	binary.BigEndian.PutUint32(resp[4:], toNtpShort(ref.rootDelay))
+	binary.BigEndian.PutUint32(resp[8:], toNtpShort(ref.rootDispersion))
+	binary.BigEndian.PutUint32(resp[12:], ref.id)
+	binary.BigEndian.PutUint64(resp[16:], toNtpTime(ref.time))
+	copy(resp[24:32], req[40:48]) // originate = transmit клиента
+	binary.BigEndian.PutUint64(resp[32:], toNtpTime(recv))
+	binary.BigEndian.PutUint64(resp[40:], toNtpTime(s.now()))
+	return resp, nil
+}
+
+// точность часов в log2 секунд (~1 мкс)
+var precision int8 = -20
+
+// перевод времени в 64-битный формат NTP (32.32)
+func toNtpTime(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	d := t.Sub(ntpEpoch)
+	sec := uint64(d / time.Second)
+	frac := uint64(d%time.Second) << 32 / uint64(time.Second)
+	return sec<<32 | frac
+}
+
+// перевод длительности в 32-битный формат NTP (16.16)
+func toNtpShort(d time.Duration) uint32 {
+	if d < 0 {
+		return 0
+	}
+	v := d.Seconds() * (1 << 16)
+	if v > math.MaxUint32 {
+		return math.MaxUint32
+	}
+	return uint32(v)
+}
+
+// идентификатор вышестоящего сервера: IPv4-адрес или первые 4 байта MD5 от IPv6
+func referenceID(server string) uint32 {
+	host, _, err := net.SplitHostPort(server)
+	if err != nil {
+		host = server
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil || len(ips) == 0 {
+		return 0
+	}
+	if ip4 := ips[0].To4(); ip4 != nil {
+		return binary.BigEndian.Uint32(ip4)
+	}
+	sum := md5.Sum(ips[0])
+	return binary.BigEndian.Uint32(sum[:4])
+}
+
+// подстройка под вышестоящие серверы: поправка берется из согласованного времени,
+// сведения об источнике - от ближайшего честного сервера
+func (s *sntpServer) syncUpstream(upstream queryParams) error {
+	res, err := upstream.run()
+	if err != nil {
+		return err
+	}
+	best := res.Truechimers[0]
+	for _, t := range res.Truechimers[1:] {
+		if t.Response.RootDistance < best.Response.RootDistance {
+			best = t
+		}
+	}
+	resp := best.Response
+	s.setReference(res.Offset, reference{
+		stratum:        resp.Stratum + 1,
+		id:             referenceID(best.Server),
+		time:           time.Now().Add(res.Offset),
+		rootDelay:      resp.RootDelay + resp.RTT,
+		rootDispersion: resp.RootDispersion + (res.High-res.Low)/2,
+		leap:           resp.Leap,
+	})
+	return nil
+}
+
+// режим serve: SNTP-сервер
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":123", "UDP address to listen on")
+	query := addQueryFlags(fs, "upstream", "", "comma-separated list of upstream NTP servers (local clock if empty)")
+	interval := fs.Duration("interval", 64*time.Second, "upstream synchronization interval")
+	maxAge := fs.Duration("max-age", 0, "announce the clock as unsynchronized after this long without upstream synchronization (default 4 intervals)")
+	stratum := fs.Uint("stratum", 10, "stratum announced when serving the local clock")
+	refID := fs.String("refid", "LOCL", "reference ID announced when serving the local clock")
+	fs.Parse(args)
+	if *interval <= 0 {
+		log.Fatalf("invalid interval %v: must be positive", *interval)
+	}
+	if *stratum < 1 || *stratum > 15 {
+		// 0 - kiss-o'-death, 16 и больше - несинхронизированный сервер
+		log.Fatalf("invalid stratum %d: must be from 1 to 15", *stratum)
+	}
+	if *maxAge < 0 {
+		log.Fatalf("invalid max-age %v: must not be negative", *maxAge)
+	}
+	if *maxAge == 0 {
+		*maxAge = 4 * *interval
+	}
+
+	conn, err := net.ListenPacket("udp", *addr)
+	if err != nil {
+		log.Fatalf("listening error: %v", err)
+	}
+	defer conn.Close()
+
+	s := &sntpServer{conn: conn, maxAge: *maxAge}
+	var id [4]byte
+	copy(id[:], *refID)
+	s.setReference(0, reference{
+		stratum: uint8(*stratum),
+		id:      binary.BigEndian.Uint32(id[:]),
+	})
+
+	//периодически подстраиваемся под вышестоящие серверы
+	upstream, err := query.parse()
+	if err != nil {
+		log.Fatalf("authentication keys error: %v", err)
+	}
+	if len(upstream.servers) > 0 {
+		if err := s.syncUpstream(upstream); err != nil {
+			log.Fatalf("upstream synchronization error: %v", err)
+		}
+		go func() {
+			for range time.Tick(*interval) {
+				if err := s.syncUpstream(upstream); err != nil {
+					log.Printf("upstream synchronization error: %v", err)
+				}
+			}
+		}()
+	}
+
+	log.Printf("serving SNTP on %v", conn.LocalAddr())
+	if err := s.serve(); err != nil {
+		log.Fatalf("serving error: %v", err)
+	}
+}
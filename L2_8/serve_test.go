@@ -0,0 +1,159 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"WB_L2/L2_8/clock"
+	"github.com/beevik/ntp"
+)
+
+// запуск локального SNTP-сервера со сдвигом часов offset
+func startServer(t *testing.T, offset time.Duration) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &sntpServer{conn: conn}
+	// на loopback задержка в микросекунды, поэтому погрешность задаем как
+	// у настоящего сервера второго уровня - иначе интервалы почти точечные
+	s.setReference(offset, reference{stratum: 2, id: 0x7f000001, rootDispersion: 10 * time.Millisecond})
+	go s.serve()
+	t.Cleanup(func() { conn.Close() })
+	return conn.LocalAddr().String()
+}
+
+func TestServeQuery(t *testing.T) {
+	addr := startServer(t, time.Hour)
+
+	resp, err := ntp.QueryWithOptions(addr, ntp.QueryOptions{Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if err := resp.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if resp.Stratum != 2 || resp.ReferenceString() != "127.0.0.1" {
+		t.Errorf("unexpected stratum %d or reference %s", resp.Stratum, resp.ReferenceString())
+	}
+	if diff := resp.ClockOffset - time.Hour; diff < -100*time.Millisecond || diff > 100*time.Millisecond {
+		t.Errorf("expected offset about 1h, got %v", resp.ClockOffset)
+	}
+}
+
+func TestServeConsensus(t *testing.T) {
+	// два сервера с верным временем и один, спешащий на минуту
+	servers := []string{
+		startServer(t, 0),
+		startServer(t, time.Minute),
+		startServer(t, 0),
+	}
+
+	res, err := clock.SelectConsensus(clock.QueryAll(servers, ntp.QueryOptions{Timeout: time.Second}, nil))
+	if err != nil {
+		t.Fatalf("consensus: %v", err)
+	}
+	if len(res.Truechimers) != 2 || len(res.Falsetickers) != 1 || res.Falsetickers[0].Server != servers[1] {
+		t.Errorf("expected %s to be the only falseticker, got %v", servers[1], serverNames(res.Falsetickers))
+	}
+	if res.Offset < -100*time.Millisecond || res.Offset > 100*time.Millisecond {
+		t.Errorf("expected offset about 0, got %v", res.Offset)
+	}
+}
+
+func TestServeMaxAge(t *testing.T) {
+	// без подстройки дольше maxAge сервер сообщает LI=3
+	now := time.Now()
+	tests := []struct {
+		name   string
+		synced time.Time
+		leap   ntp.LeapIndicator
+	}{
+		{name: "local clock", leap: ntp.LeapNoWarning},
+		{name: "fresh", synced: now.Add(-time.Minute), leap: ntp.LeapNoWarning},
+		{name: "stale", synced: now.Add(-time.Hour), leap: ntp.LeapNotInSync},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &sntpServer{maxAge: 5 * time.Minute}
+			s.setReference(0, reference{stratum: 3, time: tt.synced})
+			req := make([]byte, ntpPacketSize)
+			req[0] = 4<<3 | modeClient
+			resp, err := s.response(req, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if leap := ntp.LeapIndicator(resp[0] >> 6); leap != tt.leap {
+				t.Errorf("got leap indicator %d, want %d", leap, tt.leap)
+			}
+		})
+	}
+}
+
+func TestServeRejectsNonClient(t *testing.T) {
+	s := &sntpServer{}
+	req := make([]byte, ntpPacketSize)
+	req[0] = 4<<3 | modeServer
+	if _, err := s.response(req, time.Now()); err == nil {
+		t.Error("expected error for server-mode request")
+	}
+	if _, err := s.response(req[:10], time.Now()); err == nil {
+		t.Error("expected error for short request")
+	}
+}
+
+// запуск сервера, подписывающего ответы ключом SHA1 (или испорченной подписью)
+func startSigningServer(t *testing.T, keyID uint32, key string, corrupt bool) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	s := &sntpServer{conn: conn}
+	s.setReference(0, reference{stratum: 2})
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			resp, err := s.response(buf[:n], s.now())
+			if err != nil {
+				continue
+			}
+			digest := sha1.Sum(append([]byte(key), resp...))
+			if corrupt {
+				digest[0] ^= 0xff
+			}
+			resp = binary.BigEndian.AppendUint32(resp, keyID)
+			conn.WriteTo(append(resp, digest[:]...), addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestAuthenticatedQuery(t *testing.T) {
+	good := startSigningServer(t, 5, "secret", false)
+	bad := startSigningServer(t, 5, "secret", true)
+	auth := &clock.Auth{
+		Keys:    clock.Keys{5: {Type: ntp.AuthSHA1, Key: "secret", KeyID: 5}},
+		Default: 5,
+	}
+
+	samples := clock.QueryAll([]string{good, bad}, ntp.QueryOptions{Timeout: time.Second}, auth)
+	if samples[0].Err != nil {
+		t.Errorf("expected valid signature, got %v", samples[0].Err)
+	}
+	if err := samples[1].Err; err == nil || !strings.Contains(err.Error(), "does not match key 5") {
+		t.Errorf("expected MAC mismatch error, got %v", err)
+	}
+}
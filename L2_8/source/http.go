@@ -0,0 +1,93 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ошибки источника HTTP
+var (
+	ErrNoDateHeader     = errors.New("response has no valid Date header")
+	ErrInconsistentHTTP = errors.New("inconsistent Date headers in successive responses")
+)
+
+// HTTP - время из заголовка Date ответов HTTP(S)-сервера. Заголовок имеет
+// точность в одну секунду, поэтому выполняется несколько запросов со сдвигом
+// внутри секунды, и интервалы возможного смещения пересекаются.
+type HTTP struct {
+	URL     string
+	Client  *http.Client // по умолчанию http.DefaultClient
+	Samples int          // число запросов, по умолчанию 4
+}
+
+// Name возвращает имя источника.
+func (h *HTTP) Name() string {
+	return "http"
+}
+
+// Measure выполняет серию запросов HEAD и оценивает смещение часов.
+func (h *HTTP) Measure(ctx context.Context) (Measurement, error) {
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	samples := h.Samples
+	if samples <= 0 {
+		samples = 4
+	}
+
+	// истинное смещение лежит в [lo, hi]: сервер поставил дату D где-то между
+	// отправкой t0 и получением t1, а реальное время сервера в [D, D+1s)
+	var lo, hi, rtt time.Duration
+	for i := 0; i < samples; i++ {
+		if i > 0 {
+			// сдвигаем следующий запрос, чтобы попасть на смену секунды
+			select {
+			case <-ctx.Done():
+				return Measurement{}, ctx.Err()
+			case <-time.After(time.Second/time.Duration(samples) + time.Second/time.Duration(4*samples)):
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, h.URL, nil)
+		if err != nil {
+			return Measurement{}, err
+		}
+		t0 := time.Now()
+		resp, err := client.Do(req)
+		t1 := time.Now()
+		if err != nil {
+			return Measurement{}, err
+		}
+		resp.Body.Close()
+
+		date, err := http.ParseTime(resp.Header.Get("Date"))
+		if err != nil {
+			return Measurement{}, fmt.Errorf("%s: %w", h.URL, ErrNoDateHeader)
+		}
+		sampleLo := date.Sub(t1)
+		sampleHi := date.Add(time.Second).Sub(t0)
+		if i == 0 || sampleLo > lo {
+			lo = sampleLo
+		}
+		if i == 0 || sampleHi < hi {
+			hi = sampleHi
+		}
+		if d := t1.Sub(t0); i == 0 || d < rtt {
+			rtt = d
+		}
+	}
+	if lo > hi {
+		return Measurement{}, ErrInconsistentHTTP
+	}
+
+	return Measurement{
+		Source:      h.Name(),
+		Offset:      (lo + hi) / 2,
+		RTT:         rtt,
+		Uncertainty: (hi - lo) / 2,
+	}, nil
+}
@@ -0,0 +1,34 @@
+package source
+
+import (
+	"context"
+
+	"WB_L2/L2_8/nts"
+)
+
+// NTS - время сервера NTP, защищенного Network Time Security.
+type NTS struct {
+	Client *nts.Client
+}
+
+// Name возвращает имя источника.
+func (n *NTS) Name() string {
+	return "nts"
+}
+
+// Measure выполняет защищенный запрос и проверяет ответ сервера.
+func (n *NTS) Measure(ctx context.Context) (Measurement, error) {
+	resp, err := n.Client.Query(ctx)
+	if err != nil {
+		return Measurement{Source: n.Name()}, err
+	}
+	if err := resp.Validate(); err != nil {
+		return Measurement{Source: n.Name()}, err
+	}
+	return Measurement{
+		Source:      n.Name(),
+		Offset:      resp.ClockOffset,
+		RTT:         resp.RTT,
+		Uncertainty: resp.RootDistance,
+	}, nil
+}
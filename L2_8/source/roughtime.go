@@ -0,0 +1,315 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+)
+
+// теги сообщений Roughtime (четыре ASCII-символа в порядке little-endian)
+const (
+	tagSIG  uint32 = 0x00474953
+	tagNONC uint32 = 0x434e4f4e
+	tagDELE uint32 = 0x454c4544
+	tagPATH uint32 = 0x48544150
+	tagRADI uint32 = 0x49444152
+	tagPUBK uint32 = 0x4b425550
+	tagMIDP uint32 = 0x5044494d
+	tagSREP uint32 = 0x50455253
+	tagMINT uint32 = 0x544e494d
+	tagROOT uint32 = 0x544f4f52
+	tagCERT uint32 = 0x54524543
+	tagMAXT uint32 = 0x5458414d
+	tagINDX uint32 = 0x58444e49
+	tagPAD  uint32 = 0xff444150
+)
+
+// параметры протокола Roughtime
+const (
+	roughtimeRequestSize = 1024
+	roughtimeNonceSize   = 64
+	roughtimeHashSize    = 64
+
+	roughtimeResponseContext   = "RoughTime v1 response signature\x00"
+	roughtimeDelegationContext = "RoughTime v1 delegation signature--\x00"
+)
+
+// ошибки источника Roughtime
+var (
+	ErrRoughtimeMessage    = errors.New("malformed Roughtime message")
+	ErrRoughtimeSignature  = errors.New("invalid Roughtime signature")
+	ErrRoughtimeMerklePath = errors.New("Roughtime response does not include the request nonce")
+	ErrRoughtimeDelegation = errors.New("Roughtime midpoint is outside the delegation validity")
+)
+
+// Roughtime - время от сервера Roughtime с проверкой подписи ответа
+// долговременным ключом сервера.
+type Roughtime struct {
+	Address   string
+	PublicKey ed25519.PublicKey
+	Timeout   time.Duration // по умолчанию 5 секунд
+}
+
+// Name возвращает имя источника.
+func (r *Roughtime) Name() string {
+	return "roughtime"
+}
+
+// Measure отправляет запрос со случайным nonce и проверяет ответ.
+func (r *Roughtime) Measure(ctx context.Context) (Measurement, error) {
+	nonce := make([]byte, roughtimeNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return Measurement{}, err
+	}
+	req := roughtimeRequest(nonce)
+
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", r.Address)
+	if err != nil {
+		return Measurement{}, err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	t0 := time.Now()
+	if _, err := conn.Write(req); err != nil {
+		return Measurement{}, err
+	}
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	t1 := time.Now()
+	if err != nil {
+		return Measurement{}, err
+	}
+
+	midpoint, radius, err := verifyRoughtime(buf[:n], nonce, r.PublicKey)
+	if err != nil {
+		return Measurement{}, err
+	}
+	rtt := t1.Sub(t0)
+	return Measurement{
+		Source:      r.Name(),
+		Offset:      midpoint.Sub(t0.Add(rtt / 2)),
+		RTT:         rtt,
+		Uncertainty: radius + rtt/2,
+	}, nil
+}
+
+// запрос: nonce, дополненный тегом PAD до 1024 байт
+func roughtimeRequest(nonce []byte) []byte {
+	// заголовок двух тегов: число тегов, одно смещение, два тега
+	const header = 4 + 4 + 2*4
+	pad := make([]byte, roughtimeRequestSize-header-len(nonce))
+	return encodeRoughtime(map[uint32][]byte{tagNONC: nonce, tagPAD: pad})
+}
+
+// encodeRoughtime кодирует сообщение: число тегов, смещения значений
+// (кроме первого), теги по возрастанию, затем значения
+func encodeRoughtime(msg map[uint32][]byte) []byte {
+	tags := make([]uint32, 0, len(msg))
+	for tag := range msg {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint32(len(tags)))
+	offset := 0
+	for i, tag := range tags {
+		if i > 0 {
+			binary.Write(&buf, binary.LittleEndian, uint32(offset))
+		}
+		offset += len(msg[tag])
+	}
+	for _, tag := range tags {
+		binary.Write(&buf, binary.LittleEndian, tag)
+	}
+	for _, tag := range tags {
+		buf.Write(msg[tag])
+	}
+	return buf.Bytes()
+}
+
+// decodeRoughtime разбирает сообщение в таблицу тег -> значение
+func decodeRoughtime(data []byte) (map[uint32][]byte, error) {
+	if len(data) < 4 || len(data)%4 != 0 {
+		return nil, ErrRoughtimeMessage
+	}
+	n := int(binary.LittleEndian.Uint32(data))
+	if n == 0 || n > 1024 || len(data) < 4*2*n {
+		return nil, ErrRoughtimeMessage
+	}
+	header := 4 * 2 * n
+	values := data[header:]
+
+	msg := make(map[uint32][]byte, n)
+	var prevTag uint32
+	for i := 0; i < n; i++ {
+		start, end := 0, len(values)
+		if i > 0 {
+			start = int(binary.LittleEndian.Uint32(data[4*i:]))
+		}
+		if i < n-1 {
+			end = int(binary.LittleEndian.Uint32(data[4*(i+1):]))
+		}
+		tag := binary.LittleEndian.Uint32(data[4*n+4*i:])
+		if start > end || end > len(values) || start%4 != 0 || (i > 0 && tag <= prevTag) {
+			return nil, ErrRoughtimeMessage
+		}
+		msg[tag] = values[start:end]
+		prevTag = tag
+	}
+	return msg, nil
+}
+
+// получение значения тега с проверкой длины (0 - любая длина)
+func roughtimeField(msg map[uint32][]byte, tag uint32, size int) ([]byte, error) {
+	v, ok := msg[tag]
+	if !ok || (size > 0 && len(v) != size) {
+		return nil, ErrRoughtimeMessage
+	}
+	return v, nil
+}
+
+// время Roughtime: микросекунды от начала эпохи Unix
+func roughtimeTime(v []byte) time.Time {
+	return time.UnixMicro(int64(binary.LittleEndian.Uint64(v)))
+}
+
+// хэши дерева Меркла: листья и узлы с разными префиксами
+func roughtimeLeaf(data []byte) []byte {
+	h := sha512.Sum512(append([]byte{0}, data...))
+	return h[:roughtimeHashSize]
+}
+
+func roughtimeNode(left, right []byte) []byte {
+	h := sha512.New()
+	h.Write([]byte{1})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)[:roughtimeHashSize]
+}
+
+// verifyRoughtime проверяет цепочку подписей и путь в дереве Меркла
+// и возвращает время сервера и радиус его погрешности
+func verifyRoughtime(data, nonce []byte, publicKey ed25519.PublicKey) (time.Time, time.Duration, error) {
+	var zero time.Time
+	resp, err := decodeRoughtime(data)
+	if err != nil {
+		return zero, 0, err
+	}
+
+	// сертификат: долговременный ключ подписывает делегированный ключ
+	certBytes, err := roughtimeField(resp, tagCERT, 0)
+	if err != nil {
+		return zero, 0, err
+	}
+	cert, err := decodeRoughtime(certBytes)
+	if err != nil {
+		return zero, 0, err
+	}
+	deleBytes, err := roughtimeField(cert, tagDELE, 0)
+	if err != nil {
+		return zero, 0, err
+	}
+	certSig, err := roughtimeField(cert, tagSIG, ed25519.SignatureSize)
+	if err != nil {
+		return zero, 0, err
+	}
+	if len(publicKey) != ed25519.PublicKeySize ||
+		!ed25519.Verify(publicKey, append([]byte(roughtimeDelegationContext), deleBytes...), certSig) {
+		return zero, 0, fmt.Errorf("delegation: %w", ErrRoughtimeSignature)
+	}
+	dele, err := decodeRoughtime(deleBytes)
+	if err != nil {
+		return zero, 0, err
+	}
+	pubk, err := roughtimeField(dele, tagPUBK, ed25519.PublicKeySize)
+	if err != nil {
+		return zero, 0, err
+	}
+	mint, err := roughtimeField(dele, tagMINT, 8)
+	if err != nil {
+		return zero, 0, err
+	}
+	maxt, err := roughtimeField(dele, tagMAXT, 8)
+	if err != nil {
+		return zero, 0, err
+	}
+
+	// подписанный ответ: делегированный ключ подписывает SREP
+	srepBytes, err := roughtimeField(resp, tagSREP, 0)
+	if err != nil {
+		return zero, 0, err
+	}
+	sig, err := roughtimeField(resp, tagSIG, ed25519.SignatureSize)
+	if err != nil {
+		return zero, 0, err
+	}
+	if !ed25519.Verify(pubk, append([]byte(roughtimeResponseContext), srepBytes...), sig) {
+		return zero, 0, fmt.Errorf("response: %w", ErrRoughtimeSignature)
+	}
+	srep, err := decodeRoughtime(srepBytes)
+	if err != nil {
+		return zero, 0, err
+	}
+	root, err := roughtimeField(srep, tagROOT, roughtimeHashSize)
+	if err != nil {
+		return zero, 0, err
+	}
+	midp, err := roughtimeField(srep, tagMIDP, 8)
+	if err != nil {
+		return zero, 0, err
+	}
+	radi, err := roughtimeField(srep, tagRADI, 4)
+	if err != nil {
+		return zero, 0, err
+	}
+
+	// путь от листа с нашим nonce до подписанного корня
+	indxBytes, err := roughtimeField(resp, tagINDX, 4)
+	if err != nil {
+		return zero, 0, err
+	}
+	path, err := roughtimeField(resp, tagPATH, 0)
+	if err != nil || len(path)%roughtimeHashSize != 0 {
+		return zero, 0, ErrRoughtimeMessage
+	}
+	index := binary.LittleEndian.Uint32(indxBytes)
+	hash := roughtimeLeaf(nonce)
+	for len(path) > 0 {
+		if index&1 == 0 {
+			hash = roughtimeNode(hash, path[:roughtimeHashSize])
+		} else {
+			hash = roughtimeNode(path[:roughtimeHashSize], hash)
+		}
+		index >>= 1
+		path = path[roughtimeHashSize:]
+	}
+	if !bytes.Equal(hash, root) {
+		return zero, 0, ErrRoughtimeMerklePath
+	}
+
+	midpoint := roughtimeTime(midp)
+	if midpoint.Before(roughtimeTime(mint)) || midpoint.After(roughtimeTime(maxt)) {
+		return zero, 0, ErrRoughtimeDelegation
+	}
+	radius := time.Duration(binary.LittleEndian.Uint32(radi)) * time.Microsecond
+	return midpoint, radius, nil
+}
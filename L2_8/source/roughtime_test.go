@@ -0,0 +1,110 @@
+package source
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// локальный сервер Roughtime, часы которого спешат на offset
+func startRoughtime(t *testing.T, offset time.Duration, longTerm ed25519.PrivateKey) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	u64 := func(v time.Time) []byte {
+		return binary.LittleEndian.AppendUint64(nil, uint64(v.UnixMicro()))
+	}
+
+	// делегированный ключ, подписанный долговременным
+	delePub, delePriv, _ := ed25519.GenerateKey(nil)
+	dele := encodeRoughtime(map[uint32][]byte{
+		tagPUBK: delePub,
+		tagMINT: u64(time.Now().Add(-time.Hour)),
+		tagMAXT: u64(time.Now().Add(24 * time.Hour)),
+	})
+	cert := encodeRoughtime(map[uint32][]byte{
+		tagDELE: dele,
+		tagSIG:  ed25519.Sign(longTerm, append([]byte(roughtimeDelegationContext), dele...)),
+	})
+
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req, err := decodeRoughtime(buf[:n])
+			if err != nil || len(req[tagNONC]) != roughtimeNonceSize {
+				continue
+			}
+			srep := encodeRoughtime(map[uint32][]byte{
+				tagROOT: roughtimeLeaf(req[tagNONC]),
+				tagMIDP: u64(time.Now().Add(offset)),
+				tagRADI: binary.LittleEndian.AppendUint32(nil, 1000),
+			})
+			resp := encodeRoughtime(map[uint32][]byte{
+				tagSIG:  ed25519.Sign(delePriv, append([]byte(roughtimeResponseContext), srep...)),
+				tagSREP: srep,
+				tagCERT: cert,
+				tagINDX: make([]byte, 4),
+				tagPATH: nil,
+			})
+			conn.WriteTo(resp, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestRoughtime(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	addr := startRoughtime(t, -time.Minute, priv)
+
+	r := &Roughtime{Address: addr, PublicKey: pub, Timeout: time.Second}
+	m, err := r.Measure(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := m.Offset + time.Minute; diff < -50*time.Millisecond || diff > 50*time.Millisecond {
+		t.Errorf("expected offset about -1m, got %v", m.Offset)
+	}
+	if m.Uncertainty < time.Millisecond {
+		t.Errorf("uncertainty must include server radius, got %v", m.Uncertainty)
+	}
+
+	// ответ, подписанный чужим ключом, отвергается
+	other, _, _ := ed25519.GenerateKey(nil)
+	r.PublicKey = other
+	if _, err := r.Measure(context.Background()); !errors.Is(err, ErrRoughtimeSignature) {
+		t.Errorf("expected signature error, got %v", err)
+	}
+}
+
+func TestDecodeRoughtime(t *testing.T) {
+	msg := map[uint32][]byte{tagNONC: make([]byte, 64), tagPAD: make([]byte, 8), tagINDX: {1, 0, 0, 0}}
+	got, err := decodeRoughtime(encodeRoughtime(msg))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for tag, v := range msg {
+		if len(got[tag]) != len(v) {
+			t.Errorf("tag %08x: got %d bytes, want %d", tag, len(got[tag]), len(v))
+		}
+	}
+	if len(roughtimeRequest(make([]byte, 64))) != roughtimeRequestSize {
+		t.Error("request must be padded to 1024 bytes")
+	}
+	for _, bad := range [][]byte{nil, {1, 0, 0}, {2, 0, 0, 0}, {0, 0, 0, 0}} {
+		if _, err := decodeRoughtime(bad); err == nil {
+			t.Errorf("expected error for %v", bad)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Package source описывает источники точного времени с общим интерфейсом:
+// NTP, заголовок Date протокола HTTP(S) и Roughtime, а также цепочку
+// источников, которые опрашиваются по очереди до первого успеха.
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"WB_L2/L2_8/clock"
+	"github.com/beevik/ntp"
+)
+
+// ErrNoSources возвращается при опросе пустой цепочки источников.
+var ErrNoSources = errors.New("no time sources configured")
+
+// Measurement - результат измерения времени источником.
+type Measurement struct {
+	// Source - имя источника, выполнившего измерение.
+	Source string
+
+	// Offset - смещение локальных часов относительно источника.
+	Offset time.Duration
+
+	// RTT - задержка запроса к источнику туда и обратно.
+	RTT time.Duration
+
+	// Uncertainty - оценка погрешности: истинное смещение лежит
+	// в [Offset-Uncertainty, Offset+Uncertainty].
+	Uncertainty time.Duration
+
+	// Consensus - подробности опроса для источника NTP.
+	Consensus *clock.Consensus
+}
+
+// Source - источник точного времени.
+type Source interface {
+	Name() string
+	Measure(ctx context.Context) (Measurement, error)
+}
+
+// NTP - согласованное время нескольких NTP-серверов.
+type NTP struct {
+	Servers []string
+	Options ntp.QueryOptions
+	Auth    *clock.Auth
+}
+
+// Name возвращает имя источника.
+func (n *NTP) Name() string {
+	return "ntp"
+}
+
+// Measure опрашивает серверы и выбирает согласованное время. Подробности
+// опроса возвращаются и при ошибке.
+func (n *NTP) Measure(ctx context.Context) (Measurement, error) {
+	opt := n.Options
+	if deadline, ok := ctx.Deadline(); ok && (opt.Timeout == 0 || time.Until(deadline) < opt.Timeout) {
+		opt.Timeout = time.Until(deadline)
+	}
+	res, err := clock.SelectConsensus(clock.QueryAll(n.Servers, opt, n.Auth))
+	m := Measurement{Source: n.Name(), Offset: res.Offset, Uncertainty: (res.High - res.Low) / 2, Consensus: &res}
+	for _, s := range res.Truechimers {
+		if m.RTT == 0 || s.Response.RTT < m.RTT {
+			m.RTT = s.Response.RTT
+		}
+	}
+	return m, err
+}
+
+// Fallback - цепочка источников, опрашиваемых по порядку до первого успеха.
+type Fallback struct {
+	Sources []Source
+
+	// OnError, если задан, вызывается для каждого отказавшего источника.
+	OnError func(name string, err error)
+}
+
+// Name возвращает имя цепочки.
+func (f *Fallback) Name() string {
+	return "fallback"
+}
+
+// Measure возвращает измерение первого ответившего источника. Если отказали
+// все, возвращается измерение первого источника и ошибки всех источников.
+func (f *Fallback) Measure(ctx context.Context) (Measurement, error) {
+	if len(f.Sources) == 0 {
+		return Measurement{}, ErrNoSources
+	}
+
+	var first Measurement
+	var errs []error
+	for i, s := range f.Sources {
+		m, err := s.Measure(ctx)
+		if err == nil {
+			return m, nil
+		}
+		if i == 0 {
+			first = m
+		}
+		if f.OnError != nil {
+			f.OnError(s.Name(), err)
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return first, errors.Join(errs...)
+}
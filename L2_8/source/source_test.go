@@ -0,0 +1,92 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// HTTP-сервер, часы которого спешат на offset
+func dateServer(offset time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(offset).UTC().Format(http.TimeFormat))
+	}))
+}
+
+func TestHTTP(t *testing.T) {
+	srv := dateServer(time.Hour)
+	defer srv.Close()
+
+	h := &HTTP{URL: srv.URL, Samples: 4}
+	m, err := h.Measure(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m.Source != "http" {
+		t.Errorf("got source %q", m.Source)
+	}
+	if diff := m.Offset - time.Hour; diff < -m.Uncertainty || diff > m.Uncertainty {
+		t.Errorf("offset %v ± %v does not cover 1h", m.Offset, m.Uncertainty)
+	}
+	// несколько запросов со сдвигом сужают интервал меньше чем до секунды
+	if m.Uncertainty > 500*time.Millisecond {
+		t.Errorf("uncertainty too large: %v", m.Uncertainty)
+	}
+}
+
+func TestHTTPNoDate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Date"] = nil
+	}))
+	defer srv.Close()
+
+	_, err := (&HTTP{URL: srv.URL, Samples: 1}).Measure(context.Background())
+	if !errors.Is(err, ErrNoDateHeader) {
+		t.Errorf("expected ErrNoDateHeader, got %v", err)
+	}
+}
+
+// источник с заранее заданным результатом
+type stub struct {
+	name string
+	m    Measurement
+	err  error
+}
+
+func (s stub) Name() string { return s.name }
+
+func (s stub) Measure(ctx context.Context) (Measurement, error) { return s.m, s.err }
+
+func TestFallback(t *testing.T) {
+	down := errors.New("udp blocked")
+	var failed []string
+	f := &Fallback{
+		Sources: []Source{
+			stub{name: "ntp", m: Measurement{Source: "ntp"}, err: down},
+			stub{name: "http", m: Measurement{Source: "http", Offset: time.Second}},
+			stub{name: "roughtime", err: down},
+		},
+		OnError: func(name string, err error) { failed = append(failed, name) },
+	}
+
+	m, err := f.Measure(context.Background())
+	if err != nil || m.Source != "http" || m.Offset != time.Second {
+		t.Errorf("expected http measurement, got %+v (%v)", m, err)
+	}
+	if len(failed) != 1 || failed[0] != "ntp" {
+		t.Errorf("expected only ntp to fail, got %v", failed)
+	}
+
+	f.Sources = []Source{f.Sources[0], f.Sources[2]}
+	m, err = f.Measure(context.Background())
+	if !errors.Is(err, down) || m.Source != "ntp" {
+		t.Errorf("expected joined error and ntp measurement, got %+v (%v)", m, err)
+	}
+
+	if _, err := (&Fallback{}).Measure(context.Background()); !errors.Is(err, ErrNoSources) {
+		t.Errorf("expected ErrNoSources, got %v", err)
+	}
+}
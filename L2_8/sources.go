@@ -0,0 +1,92 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"WB_L2/L2_8/clock"
+	"WB_L2/L2_8/nts"
+	"WB_L2/L2_8/source"
+)
+
+// сервер Roughtime по умолчанию и его долговременный ключ; порядок
+// источников по умолчанию: если UDP/123 закрыт, время берется по Roughtime
+// (UDP/2002), затем из заголовка Date по HTTPS
+const (
+	defaultSources      = "ntp,roughtime,http"
+	defaultRoughtime    = "roughtime.cloudflare.com:2002"
+	defaultRoughtimeKey = "gD63hSj3ScS+wuOeGrubXlq35N1c5Lby/S+T7MNTjxo="
+	defaultNTS          = "time.cloudflare.com"
+)
+
+// sourceFlags - флаги источников времени и порядка их опроса
+type sourceFlags struct {
+	order        *string
+	httpURL      *string
+	httpSamples  *int
+	roughtime    *string
+	roughtimeKey *string
+	ntsServer    *string
+	ntsCA        *string
+}
+
+func addSourceFlags(fs *flag.FlagSet) *sourceFlags {
+	return &sourceFlags{
+		order:        fs.String("sources", defaultSources, "comma-separated time sources in fallback order: nts, ntp, http, roughtime"),
+		httpURL:      fs.String("http-url", "https://www.google.com/", "URL whose Date header is used by the http source"),
+		httpSamples:  fs.Int("http-samples", 4, "number of requests made by the http source"),
+		roughtime:    fs.String("roughtime", defaultRoughtime, "address of the Roughtime server"),
+		roughtimeKey: fs.String("roughtime-key", defaultRoughtimeKey, "base64 Ed25519 public key of the Roughtime server"),
+		ntsServer:    fs.String("nts", defaultNTS, "NTS-KE server of the nts source (host or host:port)"),
+		ntsCA:        fs.String("nts-ca", "", "PEM file with CA certificates trusted for NTS-KE instead of the system roots"),
+	}
+}
+
+// сборка цепочки источников в заданном порядке
+func (f *sourceFlags) build(q queryParams) (*source.Fallback, error) {
+	chain := &source.Fallback{}
+	for _, name := range strings.Split(*f.order, ",") {
+		switch strings.TrimSpace(name) {
+		case "ntp":
+			if len(q.servers) == 0 {
+				return nil, clock.ErrNoServers
+			}
+			chain.Sources = append(chain.Sources, &source.NTP{Servers: q.servers, Options: q.opt, Auth: q.auth})
+		case "http":
+			chain.Sources = append(chain.Sources, &source.HTTP{URL: *f.httpURL, Samples: *f.httpSamples})
+		case "roughtime":
+			key, err := base64.StdEncoding.DecodeString(*f.roughtimeKey)
+			if err != nil || len(key) != ed25519.PublicKeySize {
+				return nil, fmt.Errorf("invalid Roughtime public key %q", *f.roughtimeKey)
+			}
+			chain.Sources = append(chain.Sources, &source.Roughtime{Address: *f.roughtime, PublicKey: key, Timeout: q.opt.Timeout})
+		case "nts":
+			config := &tls.Config{}
+			if *f.ntsCA != "" {
+				pem, err := os.ReadFile(*f.ntsCA)
+				if err != nil {
+					return nil, err
+				}
+				config.RootCAs = x509.NewCertPool()
+				if !config.RootCAs.AppendCertsFromPEM(pem) {
+					return nil, fmt.Errorf("no certificates in %s", *f.ntsCA)
+				}
+			}
+			client := &nts.Client{Address: *f.ntsServer, TLSConfig: config, Timeout: q.opt.Timeout}
+			chain.Sources = append(chain.Sources, &source.NTS{Client: client})
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown time source %q", name)
+		}
+	}
+	if len(chain.Sources) == 0 {
+		return nil, source.ErrNoSources
+	}
+	return chain, nil
+}
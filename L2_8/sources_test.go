@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+)
+
+func TestSourceFlags(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		expect []string
+		err    bool
+	}{
+		// по умолчанию при недоступном NTP есть куда отступить
+		{name: "default chain", expect: []string{"ntp", "roughtime", "http"}},
+		{name: "custom order", args: []string{"-sources", "http, ntp"}, expect: []string{"http", "ntp"}},
+		{name: "unknown source", args: []string{"-sources", "gps"}, err: true},
+		{name: "empty", args: []string{"-sources", ","}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("ntp", flag.ContinueOnError)
+			f := addSourceFlags(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			chain, err := f.build(queryParams{servers: []string{"pool.ntp.org"}})
+			if tt.err {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, s := range chain.Sources {
+				names = append(names, s.Name())
+			}
+			if !reflect.DeepEqual(names, tt.expect) {
+				t.Errorf("got sources %v, want %v", names, tt.expect)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ошибки формата; UnpackError и LimitError сравниваются с ними через errors.Is
+var (
+	ErrLeadingDigit   = errors.New("string or group starts with a digit")
+	ErrDanglingEscape = errors.New("string ends with an escape character")
+	ErrInvalidEscape  = errors.New("invalid code point escape")
+	ErrInvalidCount   = errors.New("repeat count is not a decimal number")
+	ErrCountOverflow  = errors.New("repeat count overflows")
+	ErrLimitExceeded  = errors.New("limit exceeded")
+	ErrUnclosedGroup  = errors.New("group is not closed")
+	ErrUnmatchedParen = errors.New("closing parenthesis without a group")
+	ErrGroupDepth     = errors.New("groups are nested too deeply")
+	ErrTruncated      = errors.New("PackBits data ends inside a packet")
+	ErrZeroCount      = errors.New("repeat count is zero")
+	ErrRedundantCount = errors.New("repeat count of one is redundant")
+	ErrEscapeChar     = errors.New("escape character must not be a digit or a parenthesis")
+)
+
+// UnpackError - ошибка в упакованной строке с указанием места.
+type UnpackError struct {
+	Err     error  // одна из ошибок Err* или *LimitError
+	Offset  int    // смещение начала фрагмента в рунах (для PackBits - в байтах)
+	Snippet string // фрагмент ввода (для PackBits - заголовок пакета в hex)
+}
+
+func (e *UnpackError) Error() string {
+	return fmt.Sprintf("%v at offset %d: %q", e.Err, e.Offset, e.Snippet)
+}
+
+func (e *UnpackError) Unwrap() error {
+	return e.Err
+}
+
+// Render возвращает строку ввода и под ней указатель на ошибочный фрагмент:
+//
+//	qwe\
+//	   ^
+//
+// Позиция считается в графемных кластерах, табуляции сохраняются, чтобы
+// указатель оказался под нужным символом.
+func (e *UnpackError) Render(input string) string {
+	runes := []rune(input)
+	offset := min(e.Offset, len(runes))
+
+	var marker strings.Builder
+	for _, c := range graphemes(string(runes[:offset])) {
+		if c == "\t" {
+			marker.WriteByte('\t')
+		} else {
+			marker.WriteByte(' ')
+		}
+	}
+	marker.WriteByte('^')
+	if n := len(graphemes(e.Snippet)); n > 1 {
+		marker.WriteString(strings.Repeat("~", n-1))
+	}
+	return strings.TrimRight(input, "\r\n") + "\n" + marker.String()
+}
+
+// LimitError возвращается, когда распаковка превышает одно из ограничений.
+type LimitError struct {
+	Limit string // "count" или "output"
+	Max   int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s limit of %d exceeded", e.Limit, e.Max)
+}
+
+// Is позволяет проверять ошибку через errors.Is(err, ErrLimitExceeded).
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUnpackErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		limits  Limits
+		err     error
+		offset  int
+		snippet string
+		render  string
+	}{
+		{name: "цифра в начале", input: "45", err: ErrLeadingDigit, offset: 0, snippet: "45",
+			render: "45\n^~"},
+		{name: "висячий escape", input: `qwe\`, err: ErrDanglingEscape, offset: 3, snippet: `\`,
+			render: "qwe\\\n   ^"},
+		{name: "кириллица перед escape", input: `жёлудь\`, err: ErrDanglingEscape, offset: 6, snippet: `\`,
+			render: "жёлудь\\\n      ^"},
+		{name: "переполнение", input: "ab99999999999999999999c", err: ErrCountOverflow, offset: 2, snippet: "9999999999999999999",
+			render: "ab99999999999999999999c\n  ^" + strings.Repeat("~", 18)},
+		{name: "не десятичная цифра", input: "a1٣", err: ErrInvalidCount, offset: 1, snippet: "1٣",
+			render: "a1٣\n ^~"},
+		{name: "ограничение числа", input: "x\ty100", limits: Limits{MaxCount: 50}, err: ErrLimitExceeded, offset: 3, snippet: "100",
+			render: "x\ty100\n \t ^~~"},
+		{name: "ограничение размера", input: `ab\49`, limits: Limits{MaxOutput: 8}, err: ErrLimitExceeded, offset: 2, snippet: `\49`,
+			render: "ab\\49\n  ^~~"},
+		{name: "неполный код символа", input: `ab\u04`, err: ErrInvalidEscape, offset: 2, snippet: `\u04`,
+			render: "ab\\u04\n  ^~~~"},
+		{name: "суррогат", input: `\uDC00x`, err: ErrInvalidEscape, offset: 0, snippet: `\uDC00`,
+			render: "\\uDC00x\n^~~~~~"},
+		{name: "цифра в начале группы", input: "a(3b)", err: ErrLeadingDigit, offset: 2, snippet: "3",
+			render: "a(3b)\n  ^"},
+		{name: "незакрытая группа", input: "x(ab(c)2", err: ErrUnclosedGroup, offset: 1, snippet: "(",
+			render: "x(ab(c)2\n ^"},
+		{name: "лишняя скобка", input: "(ab)2)", err: ErrUnmatchedParen, offset: 5, snippet: ")",
+			render: "(ab)2)\n     ^"},
+		{name: "слишком глубокая вложенность", input: strings.Repeat("(", maxGroupDepth+1) + "a", err: ErrGroupDepth, offset: maxGroupDepth, snippet: "(",
+			render: strings.Repeat("(", maxGroupDepth+1) + "a\n" + strings.Repeat(" ", maxGroupDepth) + "^"},
+		{name: "ограничение размера группы", input: "x(ab)3", limits: Limits{MaxOutput: 6}, err: ErrLimitExceeded, offset: 1, snippet: "(ab)3",
+			render: "x(ab)3\n ^~~~~"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := unpackString(tt.input, tt.limits)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Expected %v, got %v (input: %q)", tt.err, err, tt.input)
+			}
+			var unpackErr *UnpackError
+			if !errors.As(err, &unpackErr) {
+				t.Fatalf("Expected *UnpackError, got %T", err)
+			}
+			if unpackErr.Offset != tt.offset || unpackErr.Snippet != tt.snippet {
+				t.Errorf("Expected offset %d snippet %q, got %d %q", tt.offset, tt.snippet, unpackErr.Offset, unpackErr.Snippet)
+			}
+			if res := unpackErr.Render(tt.input); res != tt.render {
+				t.Errorf("Expected rendering\n%s\ngot\n%s", tt.render, res)
+			}
+		})
+	}
+}
+
+func TestLimitErrorAs(t *testing.T) {
+	_, err := unpackString("a1000", Limits{MaxCount: 10})
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != "count" || limitErr.Max != 10 {
+		t.Errorf("Expected count *LimitError, got %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import "unicode"
+
+// свойства символов для границ расширенных графемных кластеров (UAX #29)
+type graphemeProperty int
+
+const (
+	gpOther graphemeProperty = iota
+	gpCR
+	gpLF
+	gpControl
+	gpExtend
+	gpZWJ
+	gpRegional
+	gpSpacingMark
+	gpL
+	gpV
+	gpT
+	gpLV
+	gpLVT
+	gpPictographic
+)
+
+// диапазоны Extended_Pictographic (без региональных индикаторов и модификаторов)
+var pictographic = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x00a9, Hi: 0x00a9, Stride: 1},
+		{Lo: 0x00ae, Hi: 0x00ae, Stride: 1},
+		{Lo: 0x203c, Hi: 0x203c, Stride: 1},
+		{Lo: 0x2049, Hi: 0x2049, Stride: 1},
+		{Lo: 0x2122, Hi: 0x2122, Stride: 1},
+		{Lo: 0x2139, Hi: 0x2139, Stride: 1},
+		{Lo: 0x2194, Hi: 0x2199, Stride: 1},
+		{Lo: 0x21a9, Hi: 0x21aa, Stride: 1},
+		{Lo: 0x231a, Hi: 0x231b, Stride: 1},
+		{Lo: 0x2328, Hi: 0x2328, Stride: 1},
+		{Lo: 0x2388, Hi: 0x2388, Stride: 1},
+		{Lo: 0x23cf, Hi: 0x23cf, Stride: 1},
+		{Lo: 0x23e9, Hi: 0x23f3, Stride: 1},
+		{Lo: 0x23f8, Hi: 0x23fa, Stride: 1},
+		{Lo: 0x24c2, Hi: 0x24c2, Stride: 1},
+		{Lo: 0x25aa, Hi: 0x25ab, Stride: 1},
+		{Lo: 0x25b6, Hi: 0x25b6, Stride: 1},
+		{Lo: 0x25c0, Hi: 0x25c0, Stride: 1},
+		{Lo: 0x25fb, Hi: 0x25fe, Stride: 1},
+		{Lo: 0x2600, Hi: 0x27bf, Stride: 1},
+		{Lo: 0x2934, Hi: 0x2935, Stride: 1},
+		{Lo: 0x2b05, Hi: 0x2b07, Stride: 1},
+		{Lo: 0x2b1b, Hi: 0x2b1c, Stride: 1},
+		{Lo: 0x2b50, Hi: 0x2b50, Stride: 1},
+		{Lo: 0x2b55, Hi: 0x2b55, Stride: 1},
+		{Lo: 0x3030, Hi: 0x3030, Stride: 1},
+		{Lo: 0x303d, Hi: 0x303d, Stride: 1},
+		{Lo: 0x3297, Hi: 0x3297, Stride: 1},
+		{Lo: 0x3299, Hi: 0x3299, Stride: 1},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x1f000, Hi: 0x1f1e5, Stride: 1},
+		{Lo: 0x1f200, Hi: 0x1f3fa, Stride: 1},
+		{Lo: 0x1f400, Hi: 0x1faff, Stride: 1},
+		{Lo: 0x1fc00, Hi: 0x1fffd, Stride: 1},
+	},
+}
+
+func graphemePropertyOf(r rune) graphemeProperty {
+	switch {
+	case r >= 0x20 && r < 0x7f:
+		// печатные символы ASCII встречаются чаще всего
+		return gpOther
+	case r == '\r':
+		return gpCR
+	case r == '\n':
+		return gpLF
+	case r == 0x200d:
+		return gpZWJ
+	case r == 0x200c, r >= 0x1f3fb && r <= 0x1f3ff, r >= 0xe0020 && r <= 0xe007f:
+		// ZWNJ, модификаторы цвета кожи и теги эмодзи
+		return gpExtend
+	case r >= 0x1f1e6 && r <= 0x1f1ff:
+		return gpRegional
+	case unicode.In(r, unicode.Cc, unicode.Cf, unicode.Zl, unicode.Zp):
+		return gpControl
+	case unicode.In(r, unicode.Mn, unicode.Me):
+		return gpExtend
+	case unicode.Is(unicode.Mc, r):
+		return gpSpacingMark
+	case r >= 0x1100 && r <= 0x115f, r >= 0xa960 && r <= 0xa97c:
+		return gpL
+	case r >= 0x1160 && r <= 0x11a7, r >= 0xd7b0 && r <= 0xd7c6:
+		return gpV
+	case r >= 0x11a8 && r <= 0x11ff, r >= 0xd7cb && r <= 0xd7fb:
+		return gpT
+	case r >= 0xac00 && r <= 0xd7a3:
+		if (r-0xac00)%28 == 0 {
+			return gpLV
+		}
+		return gpLVT
+	case unicode.Is(pictographic, r):
+		return gpPictographic
+	}
+	return gpOther
+}
+
+// segmenter находит границы графемных кластеров в потоке символов
+type segmenter struct {
+	prev     graphemeProperty
+	started  bool
+	pict     bool // перед текущей позицией Extended_Pictographic Extend*
+	pictZWJ  bool // перед текущей позицией Extended_Pictographic Extend* ZWJ
+	regional int  // число региональных индикаторов подряд перед текущей позицией
+}
+
+// next учитывает символ r и сообщает, начинается ли с него новый кластер
+func (s *segmenter) next(r rune) bool {
+	p := graphemePropertyOf(r)
+	boundary := s.boundary(p)
+
+	s.pictZWJ = p == gpZWJ && s.pict
+	s.pict = p == gpPictographic || (p == gpExtend && s.pict)
+	if p == gpRegional {
+		s.regional++
+	} else {
+		s.regional = 0
+	}
+	s.prev = p
+	s.started = true
+	return boundary
+}
+
+// правила GB3-GB999
+func (s *segmenter) boundary(p graphemeProperty) bool {
+	prev := s.prev
+	switch {
+	case !s.started:
+		return true
+	case prev == gpCR && p == gpLF:
+		return false
+	case prev == gpCR || prev == gpLF || prev == gpControl:
+		return true
+	case p == gpCR || p == gpLF || p == gpControl:
+		return true
+	case prev == gpL && (p == gpL || p == gpV || p == gpLV || p == gpLVT):
+		return false
+	case (prev == gpLV || prev == gpV) && (p == gpV || p == gpT):
+		return false
+	case (prev == gpLVT || prev == gpT) && p == gpT:
+		return false
+	case p == gpExtend || p == gpZWJ || p == gpSpacingMark:
+		return false
+	case s.pictZWJ && p == gpPictographic:
+		return false
+	case prev == gpRegional && p == gpRegional:
+		// флаги - пары региональных индикаторов
+		return s.regional%2 == 0
+	}
+	return true
+}
+
+// graphemes разбивает строку на расширенные графемные кластеры
+func graphemes(s string) []string {
+	var clusters []string
+	var seg segmenter
+	start := 0
+	for i, r := range s {
+		if seg.next(r) && i > start {
+			clusters = append(clusters, s[start:i])
+			start = i
+		}
+	}
+	if start < len(s) {
+		clusters = append(clusters, s[start:])
+	}
+	return clusters
+}
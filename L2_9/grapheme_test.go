@@ -0,0 +1,33 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGraphemes(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{name: "empty", input: "", expected: nil},
+		{name: "ascii", input: "ab", expected: []string{"a", "b"}},
+		{name: "кириллица", input: "ёж", expected: []string{"ё", "ж"}},
+		{name: "combining", input: "е\u0308ж", expected: []string{"е\u0308", "ж"}},
+		{name: "crlf", input: "a\r\nb", expected: []string{"a", "\r\n", "b"}},
+		{name: "zwj", input: "👩\u200d💻x", expected: []string{"👩\u200d💻", "x"}},
+		{name: "modifier", input: "👋🏿👋", expected: []string{"👋🏿", "👋"}},
+		{name: "flags", input: "🇷🇺🇩🇪🇫", expected: []string{"🇷🇺", "🇩🇪", "🇫"}},
+		{name: "hangul jamo", input: "\u1100\u1161\u11a8가", expected: []string{"\u1100\u1161\u11a8", "가"}},
+		{name: "zwj without emoji", input: "a\u200db", expected: []string{"a\u200d", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if res := graphemes(tt.input); !reflect.DeepEqual(res, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, res)
+			}
+		})
+	}
+}
@@ -1,77 +1,228 @@
 package main
 
 import (
+	"bufio"
 	"errors"
-	"strconv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
-func resolveString(s string) (string, error) {
-	escape := false
-	var prevSymbol rune
-	answer := strings.Builder{}
-	runes := []rune(s) // для корректной обработки Unicode
-
-	for i := 0; i < len(runes); i++ {
-		v := runes[i]
-		switch {
-		case escape:
-			// после \ добавляем любой символ
-			answer.WriteRune(v)
-			prevSymbol = v
-			escape = false
-
-		case v == '\\':
-			// найден \, тогда ставим escape = true
-			escape = true
-
-		case unicode.IsDigit(v):
-			if i == 0 {
-				// если первый символ является цифрой, то возвращаем ошибку
-				return "", errors.New("invalid string: starts with digit")
-			}
+// коды возврата
+const (
+	exitOK      = 0 // все строки обработаны
+	exitInvalid = 1 // в некоторых строках ошибки формата
+	exitFailure = 2 // ошибка в аргументах, чтении или записи
+)
 
-			// получаем число в цикле, учитывая, что число может состоять из 2 и более разрядов
-			numStr := string(v)
-			j := i + 1
-			for j < len(runes) && unicode.IsDigit(runes[j]) {
-				numStr += string(runes[j])
-				j++
-			}
+type config struct {
+	format string
+	encode bool
+	strict bool
+	output string
+	limits Limits
+	files  []string
+}
 
-			// после считывания преобразуем в число
-			count, err := strconv.Atoi(numStr)
-			if err != nil {
-				return "", errors.New("invalid number format")
-			}
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
 
-			if count == 0 {
-				// если число 0, удаляем предыдущий символ
-				if answer.Len() > 0 {
-					temp := answer.String()
-					answer.Reset()
-					answer.WriteString(temp[:len(temp)-1])
+// разбор флагов; ошибки выводятся в stderr вместе со справкой
+func parseFlags(args []string, stderr io.Writer) (config, error) {
+	var cfg config
+	fs := flag.NewFlagSet("unpack", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	decode := fs.Bool("d", false, "decode packed lines (default)")
+	fs.BoolVar(&cfg.encode, "e", false, "encode lines")
+	fs.StringVar(&cfg.format, "format", "text", "format: text (line by line) or packbits (binary run-length, whole input)")
+	fs.BoolVar(&cfg.strict, "strict", false, "stop at the first invalid line or file")
+	fs.StringVar(&cfg.output, "o", "", "write output to this file instead of stdout")
+	fs.IntVar(&cfg.limits.MaxCount, "max-count", 0, "largest allowed repeat count, 0 for no limit")
+	fs.Int64Var(&cfg.limits.MaxOutput, "max-output", 0, "largest allowed size of a decoded line in bytes, 0 for no limit")
+
+	// парсим флаги
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+	if *decode && cfg.encode {
+		err := errors.New("-d and -e are mutually exclusive")
+		fmt.Fprintln(stderr, err)
+		fs.Usage()
+		return cfg, err
+	}
+
+	if cfg.format != "text" && cfg.format != "packbits" {
+		err := fmt.Errorf("unknown format %q", cfg.format)
+		fmt.Fprintln(stderr, err)
+		fs.Usage()
+		return cfg, err
+	}
+
+	// остальные аргументы - входные файлы, по умолчанию stdin
+	cfg.files = fs.Args()
+	if len(cfg.files) == 0 {
+		cfg.files = []string{"-"}
+	}
+	return cfg, nil
+}
+
+// run выполняет команду и возвращает код возврата
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	cfg, err := parseFlags(args, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		// ошибка уже выведена вместе со справкой
+		return exitFailure
+	}
+
+	// результат пишем в stdout или в файл -o
+	var file *os.File
+	if cfg.output != "" {
+		if file, err = os.Create(cfg.output); err != nil {
+			fmt.Fprintf(stderr, "unpack: %v\n", err)
+			return exitFailure
+		}
+		stdout = file
+	}
+	w := bufio.NewWriter(stdout)
+
+	status := exitOK
+	for _, name := range cfg.files {
+		var input io.Reader = stdin
+		var f *os.File
+		if name != "-" {
+			if f, err = os.Open(name); err != nil {
+				fmt.Fprintf(stderr, "unpack: %v\n", err)
+				status = exitFailure
+				if cfg.strict {
+					break
 				}
-			} else if prevSymbol != 0 {
-				// дублируем предыдущий символ count-1 раз, учитывая, что он уже есть 1 раз в строке
-				answer.WriteString(strings.Repeat(string(prevSymbol), count-1))
+				continue
 			}
+			input = f
+		}
 
-			// пропускаем обработанные цифры
-			i = j - 1
+		process := processLines
+		if cfg.format == "packbits" {
+			process = processBinary
+		}
+		invalid, err := process(name, input, w, stderr, cfg)
+		if f != nil {
+			// закрываем сразу, а не в конце run, чтобы не держать открытыми
+			// все файлы из списка
+			f.Close()
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "unpack: %v\n", err)
+			status = exitFailure
+			break
+		}
+		if invalid {
+			status = max(status, exitInvalid)
+			if cfg.strict {
+				break
+			}
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(stderr, "unpack: %v\n", err)
+		status = exitFailure
+	}
+	if file != nil {
+		if err := file.Close(); err != nil {
+			fmt.Fprintf(stderr, "unpack: %v\n", err)
+			status = exitFailure
+		}
+	}
+	return status
+}
+
+// processLines обрабатывает входной файл построчно. Ошибки формата выводятся в
+// stderr с номером строки, а строка пропускается; в режиме strict обработка
+// на ней прекращается. Возвращает, были ли ошибки формата, и ошибку ввода-вывода.
+func processLines(name string, r io.Reader, w io.Writer, stderr io.Writer, cfg config) (bool, error) {
+	if name == "-" {
+		name = "stdin"
+	}
+	reader := bufio.NewReader(r)
+	invalid := false
+	for lineNum := 1; ; lineNum++ {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return invalid, fmt.Errorf("%s: %w", name, err)
+		}
+		if line == "" {
+			return invalid, nil
+		}
+		text := strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		res, convErr := convert(text, cfg)
+		if convErr != nil {
+			fmt.Fprintf(stderr, "%s:%d: %v\n", name, lineNum, convErr)
+			var unpackErr *UnpackError
+			if errors.As(convErr, &unpackErr) {
+				fmt.Fprintln(stderr, unpackErr.Render(text))
+			}
+			invalid = true
+			if cfg.strict {
+				return invalid, nil
+			}
+		} else if _, err := io.WriteString(w, res+"\n"); err != nil {
+			return invalid, err
+		}
 
-		default:
-			// обычный символ добавляем в любом случае
-			answer.WriteRune(v)
-			prevSymbol = v
+		if err == io.EOF {
+			return invalid, nil
 		}
 	}
+}
 
-	if escape {
-		// строка закончилась на \, это ошибка
-		return "", errors.New("invalid string: ends with escape character")
+// processBinary упаковывает или распаковывает входной файл PackBits целиком.
+// При ошибке формата результат обрывается на ней.
+func processBinary(name string, r io.Reader, w io.Writer, stderr io.Writer, cfg config) (bool, error) {
+	if name == "-" {
+		name = "stdin"
+	}
+	var err error
+	if cfg.encode {
+		_, err = PackBits(w, r)
+	} else {
+		_, err = UnpackBits(w, r, cfg.limits)
 	}
+	var unpackErr *UnpackError
+	if errors.As(err, &unpackErr) {
+		fmt.Fprintf(stderr, "%s: %v\n", name, err)
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", name, err)
+	}
+	return false, nil
+}
 
-	return answer.String(), nil
+// упаковка или распаковка одной строки
+func convert(line string, cfg config) (string, error) {
+	if cfg.encode {
+		return Pack(line), nil
+	}
+	return unpackString(line, cfg.limits)
+}
+
+// digitCluster сообщает, является ли кластер одиночной цифрой
+func digitCluster(c string) bool {
+	r, size := utf8.DecodeRuneInString(c)
+	return size == len(c) && unicode.IsDigit(r)
+}
+
+// resolveString распаковывает строку вида "a4bc2d5e" целиком в памяти
+func resolveString(s string) (string, error) {
+	return unpackString(s, Limits{})
 }
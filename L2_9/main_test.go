@@ -1,6 +1,10 @@
 package main
 
 import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -33,6 +37,42 @@ func TestResolveString(t *testing.T) {
 		{number: 12, name: "ярусский", input: `ярусский`, expected: "ярусский", hasError: false},
 		{number: 13, name: "л2к5", input: `л2к5`, expected: "ллккккк", hasError: false},
 		{number: 14, name: `\4`, input: `\4`, expected: `4`, hasError: false},
+
+		// графемные кластеры
+		{number: 15, name: "кириллица с удалением", input: "ая0б3", expected: "аббб", hasError: false},
+		{number: 16, name: "ё составная", input: "е\u03083", expected: "е\u0308е\u0308е\u0308", hasError: false},
+		{number: 17, name: "й составная с удалением", input: "ми\u03060р", expected: "мр", hasError: false},
+		{number: 18, name: "e с акутом", input: "e\u03012x", expected: "e\u0301e\u0301x", hasError: false},
+		{number: 19, name: "эмодзи ZWJ", input: "👨\u200d👩\u200d👧2", expected: "👨\u200d👩\u200d👧👨\u200d👩\u200d👧", hasError: false},
+		{number: 20, name: "эмодзи с тоном кожи", input: "👍🏽3", expected: "👍🏽👍🏽👍🏽", hasError: false},
+		{number: 21, name: "флаги", input: "🇷🇺2🇰🇿0", expected: "🇷🇺🇷🇺", hasError: false},
+		{number: 22, name: "keycap не является цифрой", input: "1\ufe0f\u20e32", expected: "1\ufe0f\u20e31\ufe0f\u20e3", hasError: false},
+		{number: 23, name: "экранированная буква с диакритикой", input: `\` + "е\u03082", expected: "е\u0308е\u0308", hasError: false},
+
+		// группы
+		{number: 24, name: "(ab)3", input: "(ab)3", expected: "ababab", hasError: false},
+		{number: 25, name: "(a(bc)2)2", input: "(a(bc)2)2", expected: "abcbcabcbc", hasError: false},
+		{number: 26, name: "x(ab)c", input: "x(ab)c", expected: "xabc", hasError: false},
+		{number: 27, name: "(ab)0c", input: "(ab)0c", expected: "c", hasError: false},
+		{number: 28, name: `\(a\)2`, input: `\(a\)2`, expected: "(a))", hasError: false},
+		{number: 29, name: "(ж2)3", input: "(ж2)3", expected: "жжжжжж", hasError: false},
+		{number: 30, name: "()5a", input: "()5a", expected: "a", hasError: false},
+		{number: 31, name: "(ab", input: "(ab", expected: "", hasError: true},
+		{number: 32, name: "ab)", input: "ab)", expected: "", hasError: true},
+		{number: 33, name: "(2a)", input: "(2a)", expected: "", hasError: true},
+
+		// управляющие последовательности
+		{number: 34, name: `a\nb`, input: `a\nb`, expected: "a\nb", hasError: false},
+		{number: 35, name: `\t3`, input: `\t3`, expected: "\t\t\t", hasError: false},
+		{number: 36, name: `\0\\`, input: `\0\\`, expected: "\x00\\", hasError: false},
+		{number: 37, name: `\u0436\u04452`, input: `\u0436\u04452`, expected: "жхх", hasError: false},
+		{number: 38, name: `\U0001F600\U0001f6003`, input: `\U0001F600\U0001f6003`, expected: "😀😀😀😀", hasError: false},
+		{number: 39, name: `(\r\n)2`, input: `(\r\n)2`, expected: "\r\n\r\n", hasError: false},
+		{number: 40, name: `\q`, input: `\q`, expected: "q", hasError: false},
+		{number: 41, name: `\u12`, input: `\u12`, expected: "", hasError: true},
+		{number: 42, name: `\u12xy`, input: `\u12xy`, expected: "", hasError: true},
+		{number: 43, name: `\uD800`, input: `\uD800`, expected: "", hasError: true},
+		{number: 44, name: `\U00110000`, input: `\U00110000`, expected: "", hasError: true},
 	}
 
 	for _, tt := range tests {
@@ -52,3 +92,76 @@ func TestResolveString(t *testing.T) {
 		})
 	}
 }
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	packed := filepath.Join(dir, "packed.txt")
+	if err := os.WriteFile(packed, []byte("a4bc2d5e\r\n(ab)3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		args   []string
+		stdin  string
+		stdout string
+		stderr []string // фрагменты, которые должны быть в stderr
+		code   int
+	}{
+		{name: "распаковка stdin", args: nil, stdin: "a4bc2d5e\nabcd\n", stdout: "aaaabccddddde\nabcd\n", code: exitOK},
+		{name: "последняя строка без перевода", args: []string{"-d"}, stdin: "л2", stdout: "лл\n", code: exitOK},
+		{name: "упаковка", args: []string{"-e"}, stdin: "aaaabccddddde\nababab\n45\n", stdout: "a4bccd5e\n(ab)3\n\\4\\5\n", code: exitOK},
+		{name: "ошибка в строке", args: nil, stdin: "a2\n45\nb3\n", stdout: "aa\nbbb\n",
+			stderr: []string{"stdin:2: ", "45\n^~"}, code: exitInvalid},
+		{name: "strict", args: []string{"-strict"}, stdin: "a2\n45\nb3\n", stdout: "aa\n",
+			stderr: []string{"stdin:2: "}, code: exitInvalid},
+		{name: "ограничение", args: []string{"-max-output", "5"}, stdin: "a9\nb5\n", stdout: "bbbbb\n",
+			stderr: []string{"stdin:1: output limit of 5 exceeded"}, code: exitInvalid},
+		{name: "файлы", args: []string{packed, "-"}, stdin: "x3", stdout: "aaaabccddddde\nababab\nxxx\n", code: exitOK},
+		{name: "нет файла", args: []string{filepath.Join(dir, "missing")}, stderr: []string{"missing"}, code: exitFailure},
+		{name: "нет файла, дальше следующий", args: []string{filepath.Join(dir, "missing"), packed},
+			stdout: "aaaabccddddde\nababab\n", stderr: []string{"missing"}, code: exitFailure},
+		{name: "strict: нет файла", args: []string{"-strict", filepath.Join(dir, "missing"), packed},
+			stderr: []string{"missing"}, code: exitFailure},
+		{name: "взаимоисключающие флаги", args: []string{"-d", "-e"}, stderr: []string{"mutually exclusive"}, code: exitFailure},
+		{name: "неизвестный флаг", args: []string{"-x"}, stderr: []string{"-x"}, code: exitFailure},
+		{name: "неизвестный формат", args: []string{"-format", "zip"}, stderr: []string{"unknown format"}, code: exitFailure},
+		{name: "упаковка packbits", args: []string{"-format", "packbits", "-e"}, stdin: "aaaa\nb", stdout: "\xfda\x01\nb", code: exitOK},
+		{name: "распаковка packbits", args: []string{"-format", "packbits"}, stdin: "\xfda\x01\nb", stdout: "aaaa\nb", code: exitOK},
+		{name: "обрыв packbits", args: []string{"-format", "packbits"}, stdin: "\xfda\x05x", stdout: "aaaa",
+			stderr: []string{"stdin: PackBits data ends inside a packet at offset 2"}, code: exitInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+			if code != tt.code {
+				t.Errorf("Expected exit code %d, got %d (stderr: %q)", tt.code, code, stderr.String())
+			}
+			if stdout.String() != tt.stdout {
+				t.Errorf("Expected output %q, got %q", tt.stdout, stdout.String())
+			}
+			for _, s := range tt.stderr {
+				if !strings.Contains(stderr.String(), s) {
+					t.Errorf("Expected %q in stderr, got %q", s, stderr.String())
+				}
+			}
+		})
+	}
+}
+
+func TestRunOutputFile(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.txt")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-o", output}, strings.NewReader("a3\n"), &stdout, &stderr); code != exitOK {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %q)", code, stderr.String())
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "aaa\n" || stdout.Len() != 0 {
+		t.Errorf("Expected %q in file and nothing in stdout, got %q and %q", "aaa\n", data, stdout.String())
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// наибольшая длина повторяющейся подстроки (в кластерах), которую ищет Pack
+const maxPackPeriod = 64
+
+// Pack упаковывает строку в формат, который разбирает resolveString: серия
+// одинаковых графемных кластеров записывается кластером и числом повторений,
+// повторяющаяся подстрока - группой в скобках, если так короче; цифры, \ и
+// скобки экранируются, а непечатаемые символы записываются управляющими
+// последовательностями, так что результат умещается в одну строку. Для любой
+// строки s выполняется resolveString(Pack(s)) == s.
+func Pack(s string) string {
+	p := &packer{memo: map[string]string{}}
+	return p.pack(graphemes(s))
+}
+
+// packer выбирает кратчайшую запись динамическим программированием
+type packer struct {
+	memo map[string]string // записи групп по их содержимому
+}
+
+func (p *packer) pack(clusters []string) string {
+	n := len(clusters)
+	if n == 0 {
+		return ""
+	}
+
+	// same[l][i] - сколько позиций подряд начиная с i совпадает со сдвигом на l
+	maxPeriod := min(maxPackPeriod, n/2)
+	same := make([][]int, maxPeriod+1)
+	for l := 1; l <= maxPeriod; l++ {
+		same[l] = make([]int, n+1)
+		for i := n - l - 1; i >= 0; i-- {
+			if clusters[i] == clusters[i+l] {
+				same[l][i] = same[l][i+1] + 1
+			}
+		}
+	}
+
+	// cost[i] - длина кратчайшей записи clusters[i:], choice[i] - ее первый
+	// элемент: период и число повторений (0 - кластер как есть)
+	type step struct{ period, repeats int }
+	cost := make([]int, n+1)
+	choice := make([]step, n+1)
+	for i := n - 1; i >= 0; i-- {
+		literal, _ := encodeCluster(clusters[i])
+		cost[i] = len(literal) + cost[i+1]
+		choice[i] = step{period: 1}
+		for l := 1; l <= maxPeriod && i+2*l <= n; l++ {
+			repeats := 1 + same[l][i]/l
+			if repeats < 2 {
+				continue
+			}
+			end := i + repeats*l
+			// подстрока, которая сама состоит из повторов, не длиннее своего периода
+			if periodic(same, i, l) {
+				continue
+			}
+			if c := len(p.unit(clusters[i:i+l])) + len(strconv.Itoa(repeats)) + cost[end]; c < cost[i] {
+				cost[i], choice[i] = c, step{period: l, repeats: repeats}
+			}
+		}
+	}
+
+	var out strings.Builder
+	for i := 0; i < n; {
+		st := choice[i]
+		if st.repeats == 0 {
+			literal, _ := encodeCluster(clusters[i])
+			out.WriteString(literal)
+			i++
+			continue
+		}
+		out.WriteString(p.unit(clusters[i : i+st.period]))
+		out.WriteString(strconv.Itoa(st.repeats))
+		i += st.period * st.repeats
+	}
+	return out.String()
+}
+
+// собственные делители периодов
+var periodDivisors = func() [][]int {
+	divisors := make([][]int, maxPackPeriod+1)
+	for l := 2; l <= maxPackPeriod; l++ {
+		for d := 1; d < l; d++ {
+			if l%d == 0 {
+				divisors[l] = append(divisors[l], d)
+			}
+		}
+	}
+	return divisors
+}()
+
+// periodic сообщает, есть ли у clusters[i:i+l] период меньше l, кратный l
+func periodic(same [][]int, i, l int) bool {
+	for _, d := range periodDivisors[l] {
+		if same[d][i] >= l-d {
+			return true
+		}
+	}
+	return false
+}
+
+// запись повторяемого элемента: кластер или группа, содержимое которой
+// упаковывается рекурсивно и запоминается; кластер, который распаковывается
+// в несколько символов, тоже берется в скобки
+func (p *packer) unit(clusters []string) string {
+	if len(clusters) == 1 {
+		if enc, single := encodeCluster(clusters[0]); single {
+			return enc
+		}
+	}
+	key := strings.Join(clusters, "")
+	if packed, ok := p.memo[key]; ok {
+		return packed
+	}
+	packed := "(" + p.pack(clusters) + ")"
+	p.memo[key] = packed
+	return packed
+}
+
+// encodeCluster возвращает запись кластера и признак того, что она
+// распаковывается в один символ. Кластеры с особым смыслом в формате
+// экранируются; непечатаемые символы и кластер, начинающийся с
+// комбинирующего знака (он слился бы с предыдущей цифрой или скобкой),
+// записываются управляющими последовательностями по одной на символ.
+func encodeCluster(c string) (string, bool) {
+	switch {
+	case c == "0":
+		// \0 означает символ NUL
+		return `\u0030`, true
+	case c == `\`, c == "(", c == ")", digitCluster(c):
+		return `\` + c, true
+	}
+
+	escape := !breaksAfter('0', c)
+	if !escape {
+		escape = true
+		for _, r := range c {
+			if unicode.IsPrint(r) {
+				escape = false
+				break
+			}
+		}
+	}
+	if !escape {
+		return c, true
+	}
+
+	var out strings.Builder
+	for _, r := range c {
+		out.WriteString(escapeRune(r))
+	}
+	return out.String(), utf8.RuneCountInString(c) == 1
+}
+
+// управляющая последовательность для символа
+func escapeRune(r rune) string {
+	switch r {
+	case '\n':
+		return `\n`
+	case '\t':
+		return `\t`
+	case '\r':
+		return `\r`
+	case 0:
+		return `\0`
+	}
+	if r > 0xffff {
+		return fmt.Sprintf(`\U%08X`, r)
+	}
+	return fmt.Sprintf(`\u%04X`, r)
+}
+
+// breaksAfter сообщает, начнется ли кластер c с новой границы, если
+// записать его сразу после символа prev
+func breaksAfter(prev rune, c string) bool {
+	var seg segmenter
+	seg.next(prev)
+	r, _ := utf8.DecodeRuneInString(c)
+	return seg.next(r)
+}
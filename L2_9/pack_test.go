@@ -0,0 +1,97 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+func TestPack(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "empty", input: "", expected: ""},
+		{name: "из условия задачи", input: "aaaabccddddde", expected: "a4bccd5e"},
+		{name: "пары не сжимаются", input: "aabb", expected: "aabb"},
+		{name: "длинная серия", input: strings.Repeat("x", 12), expected: "x12"},
+		{name: "цифры", input: "45", expected: `\4\5`},
+		{name: "серия цифр", input: "44444", expected: `\45`},
+		{name: "обратная косая черта", input: `a\b`, expected: `a\\b`},
+		{name: "серия обратных косых черт", input: `\\\\`, expected: `\\4`},
+		{name: "кириллица", input: "ллккккк", expected: "л2к5"},
+		{name: "комбинирующий знак", input: "ёёё", expected: "ё3"},
+		{name: "эмодзи ZWJ", input: strings.Repeat("👩\u200d💻", 3), expected: "👩\u200d💻3"},
+		{name: "скобки", input: "(a)", expected: `\(a\)`},
+		{name: "группа", input: "ababab", expected: "(ab)3"},
+		{name: "короткая группа не выгодна", input: "abab", expected: "abab"},
+		{name: "вложенные группы", input: "abcbcbcabcbcbc", expected: "(a(bc)3)2"},
+		{name: "группа внутри строки", input: "xyzxyzxyzxyz!", expected: "(xyz)4!"},
+		{name: "группа кириллицы", input: strings.Repeat("да", 5), expected: "(да)5"},
+		{name: "знак после управляющего символа", input: "\n\n\n\u0301", expected: `\n3\u0301`},
+		{name: "ноль", input: "x0", expected: `x\u0030`},
+		{name: "управляющие символы", input: "a\tb\x00\x00\x00\x00c\x7f", expected: `a\tb\04c\u007F`},
+		{name: "перевод строки CRLF", input: "a\r\n\r\n\r\n", expected: `a(\r\n)3`},
+		{name: "неразрывный пробел", input: "1\u00a0000", expected: `\1\u00A0\u00303`},
+		{name: "символ вне BMP", input: "\U000e0001", expected: `\U000E0001`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if res := Pack(tt.input); res != tt.expected {
+				t.Errorf("Expected %q, got %q (input: %q)", tt.expected, res, tt.input)
+			}
+		})
+	}
+}
+
+// символы, на которых чаще всего ломается упаковка
+var packAlphabet = []string{
+	"a", "b", "я", "0", "1", "9", `\`, "(", ")", "\n", "\r", "\u0301", "\u0308", "\u200d",
+	"👩", "💻", "🏽", "🇷", "🇺", "\u1100", "\u1161", "\u11a8", "١",
+}
+
+// randomPacked генерирует строку с повторами символов из packAlphabet
+func randomPacked(values []reflect.Value, r *rand.Rand) {
+	var b strings.Builder
+	for n := r.Intn(20); n > 0; n-- {
+		// серии отдельных символов и коротких подстрок
+		var unit string
+		for k := 1 + r.Intn(3); k > 0; k-- {
+			unit += packAlphabet[r.Intn(len(packAlphabet))]
+		}
+		b.WriteString(strings.Repeat(unit, 1+r.Intn(12)))
+	}
+	values[0] = reflect.ValueOf(b.String())
+}
+
+func TestPackRoundTrip(t *testing.T) {
+	roundTrip := func(s string) bool {
+		res, err := resolveString(Pack(s))
+		return err == nil && res == s
+	}
+	if err := quick.Check(roundTrip, nil); err != nil {
+		t.Error(err)
+	}
+	if err := quick.Check(roundTrip, &quick.Config{MaxCount: 2000, Values: randomPacked}); err != nil {
+		t.Error(err)
+	}
+}
+
+// упаковка не длиннее строки, в которой только экранированы особые символы
+func TestPackNotLonger(t *testing.T) {
+	notLonger := func(s string) bool {
+		escaped := 0
+		for _, c := range graphemes(s) {
+			enc, _ := encodeCluster(c)
+			escaped += len(enc)
+		}
+		return len(Pack(s)) <= escaped
+	}
+	if err := quick.Check(notLonger, &quick.Config{MaxCount: 2000, Values: randomPacked}); err != nil {
+		t.Error(err)
+	}
+}
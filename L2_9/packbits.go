@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// PackBits (Apple, TIFF): байт заголовка n со знаком, затем данные:
+//
+//	0..127     n+1 байт как есть
+//	-127..-1   следующий байт повторяется 1-n раз
+//	-128       пропускается
+const (
+	packBitsMaxLiteral = 128
+	packBitsMaxRun     = 128
+	packBitsMinRun     = 3 // более короткие серии выгоднее писать как есть
+	packBitsNoOp       = 0x80
+)
+
+// PackBits кодирует поток r схемой PackBits и записывает результат в w.
+// Возвращает число записанных байт.
+func PackBits(w io.Writer, r io.Reader) (int64, error) {
+	e := &packBitsEncoder{w: bufio.NewWriter(w)}
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return e.written, err
+		}
+		if err := e.add(b); err != nil {
+			return e.written, err
+		}
+	}
+	if err := e.flushRun(); err != nil {
+		return e.written, err
+	}
+	if err := e.flushLiteral(); err != nil {
+		return e.written, err
+	}
+	return e.written, e.w.Flush()
+}
+
+// EncodePackBits кодирует данные схемой PackBits.
+func EncodePackBits(data []byte) []byte {
+	var out bytes.Buffer
+	PackBits(&out, bytes.NewReader(data))
+	return out.Bytes()
+}
+
+// packBitsEncoder копит текущую серию одинаковых байт и накопленные байты,
+// которые пишутся как есть
+type packBitsEncoder struct {
+	w       *bufio.Writer
+	written int64
+	literal []byte
+	run     byte
+	runLen  int
+}
+
+func (e *packBitsEncoder) add(b byte) error {
+	if e.runLen > 0 && b == e.run && e.runLen < packBitsMaxRun {
+		e.runLen++
+		return nil
+	}
+	if err := e.flushRun(); err != nil {
+		return err
+	}
+	e.run, e.runLen = b, 1
+	return nil
+}
+
+// серия записывается повтором, если она достаточно длинная, иначе
+// добавляется к байтам, которые пишутся как есть
+func (e *packBitsEncoder) flushRun() error {
+	defer func() { e.runLen = 0 }()
+	if e.runLen >= packBitsMinRun {
+		if err := e.flushLiteral(); err != nil {
+			return err
+		}
+		return e.write([]byte{byte(1 - e.runLen), e.run})
+	}
+	for i := 0; i < e.runLen; i++ {
+		e.literal = append(e.literal, e.run)
+		if len(e.literal) == packBitsMaxLiteral {
+			if err := e.flushLiteral(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (e *packBitsEncoder) flushLiteral() error {
+	if len(e.literal) == 0 {
+		return nil
+	}
+	if err := e.write(append([]byte{byte(len(e.literal) - 1)}, e.literal...)); err != nil {
+		return err
+	}
+	e.literal = e.literal[:0]
+	return nil
+}
+
+func (e *packBitsEncoder) write(p []byte) error {
+	n, err := e.w.Write(p)
+	e.written += int64(n)
+	return err
+}
+
+// UnpackBits распаковывает поток PackBits из r в w с теми же ограничениями,
+// что и Unpack. Смещение в ошибках UnpackError считается в байтах.
+func UnpackBits(w io.Writer, r io.Reader, limits Limits) (int64, error) {
+	bw := bufio.NewWriter(w)
+	written, err := unpackBits(bw, bufio.NewReader(r), limits)
+	if flushErr := bw.Flush(); err == nil {
+		err = flushErr
+	}
+	return written, err
+}
+
+// DecodePackBits распаковывает данные PackBits.
+func DecodePackBits(data []byte, limits Limits) ([]byte, error) {
+	var out bytes.Buffer
+	if _, err := UnpackBits(&out, bytes.NewReader(data), limits); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func unpackBits(w *bufio.Writer, r *bufio.Reader, limits Limits) (int64, error) {
+	var written int64
+	for offset := int64(0); ; {
+		header, err := r.ReadByte()
+		if err == io.EOF {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+		fail := func(err error) error {
+			return &UnpackError{Err: err, Offset: int(offset), Snippet: fmt.Sprintf("%02x", header)}
+		}
+
+		var count int
+		var data []byte
+		switch {
+		case header == packBitsNoOp:
+			offset++
+			continue
+		case header < packBitsNoOp:
+			// байты как есть
+			count = 1
+			data = make([]byte, int(header)+1)
+		default:
+			// повтор одного байта
+			count = 1 - int(int8(header))
+			data = make([]byte, 1)
+			if max := limits.MaxCount; max > 0 && count > max {
+				return written, fail(&LimitError{Limit: "count", Max: int64(max)})
+			}
+		}
+		if _, err := io.ReadFull(r, data); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return written, fail(ErrTruncated)
+			}
+			return written, err
+		}
+
+		size := int64(count * len(data))
+		if max := limits.MaxOutput; max > 0 && size > max-written {
+			return written, fail(&LimitError{Limit: "output", Max: max})
+		}
+		for i := 0; i < count; i++ {
+			if _, err := w.Write(data); err != nil {
+				return written, err
+			}
+		}
+		written += size
+		offset += 1 + int64(len(data))
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"testing/iotest"
+	"testing/quick"
+)
+
+func TestPackBits(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []byte
+		expected []byte
+	}{
+		{name: "empty", input: nil, expected: nil},
+		// пример из Apple Technical Note TN1023
+		{name: "apple",
+			input: []byte{
+				0xaa, 0xaa, 0xaa, 0x80, 0x00, 0x2a, 0xaa, 0xaa, 0xaa, 0xaa, 0x80, 0x00,
+				0x2a, 0x22, 0xaa, 0xaa, 0xaa, 0xaa, 0xaa, 0xaa, 0xaa, 0xaa, 0xaa, 0xaa,
+			},
+			expected: []byte{0xfe, 0xaa, 0x02, 0x80, 0x00, 0x2a, 0xfd, 0xaa, 0x03, 0x80, 0x00, 0x2a, 0x22, 0xf7, 0xaa}},
+		{name: "короткие серии как есть", input: []byte("aabbc"), expected: []byte("\x04aabbc")},
+		{name: "длинная серия", input: bytes.Repeat([]byte{7}, 131), expected: []byte{0x81, 7, 0xfe, 7}},
+		{name: "длинный литерал", input: bytes.Repeat([]byte("ab"), 65),
+			expected: append(append([]byte{0x7f}, bytes.Repeat([]byte("ab"), 64)...), 0x01, 'a', 'b')},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := EncodePackBits(tt.input)
+			if !bytes.Equal(res, tt.expected) {
+				t.Errorf("Expected % x, got % x", tt.expected, res)
+			}
+			dec, err := DecodePackBits(res, Limits{})
+			if err != nil || !bytes.Equal(dec, tt.input) {
+				t.Errorf("Round trip failed: % x, %v", dec, err)
+			}
+		})
+	}
+}
+
+func TestUnpackBits(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []byte
+		limits   Limits
+		expected []byte
+		err      error
+		offset   int
+	}{
+		{name: "пропуск -128", input: []byte{0x80, 0x00, 'x', 0x80}, expected: []byte("x")},
+		{name: "обрыв литерала", input: []byte{0x00, 'x', 0x02, 'a'}, err: ErrTruncated, offset: 2},
+		{name: "обрыв повтора", input: []byte{0xfe}, err: ErrTruncated, offset: 0},
+		{name: "ограничение числа", input: []byte{0x00, 'x', 0xf0, 'a'}, limits: Limits{MaxCount: 10}, err: ErrLimitExceeded, offset: 2},
+		{name: "ограничение размера", input: []byte{0x81, 'a', 0x81, 'b'}, limits: Limits{MaxOutput: 200}, err: ErrLimitExceeded, offset: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := DecodePackBits(tt.input, tt.limits)
+			if tt.err == nil {
+				if err != nil || !bytes.Equal(res, tt.expected) {
+					t.Errorf("Expected % x, got % x, %v", tt.expected, res, err)
+				}
+				return
+			}
+			var unpackErr *UnpackError
+			if !errors.Is(err, tt.err) || !errors.As(err, &unpackErr) || unpackErr.Offset != tt.offset {
+				t.Errorf("Expected %v at offset %d, got %v", tt.err, tt.offset, err)
+			}
+		})
+	}
+}
+
+func TestPackBitsRoundTrip(t *testing.T) {
+	roundTrip := func(data []byte, runs []uint8) bool {
+		// добавляем серии, чтобы проверить и повторы
+		for i, n := range runs {
+			data = append(data, bytes.Repeat([]byte{byte(i)}, int(n))...)
+		}
+		var packed, unpacked bytes.Buffer
+		if _, err := PackBits(&packed, iotest.OneByteReader(bytes.NewReader(data))); err != nil {
+			return false
+		}
+		if _, err := UnpackBits(&unpacked, iotest.HalfReader(&packed), Limits{}); err != nil {
+			return false
+		}
+		return bytes.Equal(unpacked.Bytes(), data)
+	}
+	if err := quick.Check(roundTrip, nil); err != nil {
+		t.Error(err)
+	}
+}
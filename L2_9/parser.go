@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// грамматика упакованной строки:
+//
+//	sequence = { item }
+//	item     = atom [ count ]
+//	atom     = symbol | escape | "(" sequence ")"
+//	escape   = "\" ( "n" | "t" | "r" | "0" | "u" hex4 | "U" hex8 | any )
+//	count    = digit { digit }
+//
+// число 0 удаляет элемент, отсутствие числа означает одно повторение;
+// отступления от этих правил задаются полями Unpacker
+
+// наибольшая вложенность групп
+const maxGroupDepth = 256
+
+// виды лексем
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenSymbol
+	tokenCount
+	tokenOpen
+	tokenClose
+)
+
+// token - лексема с местом во вводе
+type token struct {
+	kind   tokenKind
+	value  string // символ для tokenSymbol
+	count  int    // значение для tokenCount
+	text   string // исходный текст лексемы
+	offset int    // смещение в рунах
+}
+
+// lexer выделяет лексемы из потока графемных кластеров
+type lexer struct {
+	r      *bufio.Reader
+	opts   Unpacker
+	escape string
+	digits bool // цифры - обычные символы (в начале элемента при LiteralDigits)
+
+	seg     segmenter
+	carry   []byte // первый символ следующего кластера
+	hasRune bool
+	offset  int // смещение следующего кластера в рунах
+
+	peeked  *token
+	pending *cluster // кластер, прочитанный при разборе числа
+}
+
+// cluster - графемный кластер и его смещение
+type cluster struct {
+	text   string
+	offset int
+}
+
+// чтение следующего символа; некорректный UTF-8 переносится как есть
+func (l *lexer) readRune() (rune, []byte, error) {
+	c, size, err := l.r.ReadRune()
+	if err != nil {
+		return 0, nil, err
+	}
+	if c == utf8.RuneError && size == 1 {
+		l.r.UnreadRune()
+		b, _ := l.r.ReadByte()
+		return c, []byte{b}, nil
+	}
+	return c, []byte(string(c)), nil
+}
+
+// чтение кластера: его конец виден только по следующему символу, поэтому
+// первый символ следующего кластера переносится в carry
+func (l *lexer) nextCluster() (*cluster, error) {
+	if l.pending != nil {
+		c := l.pending
+		l.pending = nil
+		return c, nil
+	}
+	if !l.hasRune {
+		c, raw, err := l.readRune()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		l.seg.next(c)
+		l.carry, l.hasRune = raw, true
+	}
+
+	text := append([]byte(nil), l.carry...)
+	runes := 1
+	l.hasRune = false
+	for {
+		c, raw, err := l.readRune()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if l.seg.next(c) {
+			l.carry, l.hasRune = raw, true
+			break
+		}
+		text = append(text, raw...)
+		runes++
+	}
+
+	res := &cluster{text: string(text), offset: l.offset}
+	l.offset += runes
+	return res, nil
+}
+
+func (l *lexer) peek() (*token, error) {
+	if l.peeked == nil {
+		t, err := l.scan()
+		if err != nil {
+			return nil, err
+		}
+		l.peeked = t
+	}
+	return l.peeked, nil
+}
+
+func (l *lexer) next() (*token, error) {
+	t, err := l.peek()
+	l.peeked = nil
+	return t, err
+}
+
+func (l *lexer) scan() (*token, error) {
+	c, err := l.nextCluster()
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return &token{kind: tokenEOF, offset: l.offset}, nil
+	}
+
+	switch {
+	case c.text == l.escape:
+		// после символа экранирования берем любой символ как есть, кроме
+		// управляющих последовательностей
+		escaped, err := l.nextCluster()
+		if err != nil {
+			return nil, err
+		}
+		if escaped == nil {
+			return nil, &UnpackError{Err: ErrDanglingEscape, Offset: c.offset, Snippet: c.text}
+		}
+		return l.scanEscape(c, escaped)
+
+	case c.text == "(":
+		return &token{kind: tokenOpen, text: c.text, offset: c.offset}, nil
+
+	case c.text == ")":
+		return &token{kind: tokenClose, text: c.text, offset: c.offset}, nil
+
+	case digitCluster(c.text) && l.digits:
+		return l.scanDigits(c)
+
+	case digitCluster(c.text):
+		return l.scanCount(c)
+	}
+	return &token{kind: tokenSymbol, value: c.text, text: c.text, offset: c.offset}, nil
+}
+
+// управляющие последовательности \n \t \r \0 и коды символов \uXXXX, \U00XXXXXX
+func (l *lexer) scanEscape(start, escaped *cluster) (*token, error) {
+	t := &token{kind: tokenSymbol, text: start.text + escaped.text, offset: start.offset}
+	digits := 0
+	switch escaped.text {
+	case "n":
+		t.value = "\n"
+	case "t":
+		t.value = "\t"
+	case "r":
+		t.value = "\r"
+	case "0":
+		t.value = "\x00"
+	case "u":
+		digits = 4
+	case "U":
+		digits = 8
+	default:
+		t.value = escaped.text
+	}
+	if digits == 0 {
+		return t, nil
+	}
+
+	var code rune
+	for i := 0; i < digits; i++ {
+		c, err := l.nextCluster()
+		if err != nil {
+			return nil, err
+		}
+		if c == nil {
+			return nil, &UnpackError{Err: ErrInvalidEscape, Offset: t.offset, Snippet: t.text}
+		}
+		t.text += c.text
+		v, ok := hexValue(c.text)
+		if !ok {
+			return nil, &UnpackError{Err: ErrInvalidEscape, Offset: t.offset, Snippet: t.text}
+		}
+		code = code<<4 | v
+	}
+	if !utf8.ValidRune(code) {
+		return nil, &UnpackError{Err: ErrInvalidEscape, Offset: t.offset, Snippet: t.text}
+	}
+	t.value = string(code)
+	return t, nil
+}
+
+func hexValue(c string) (rune, bool) {
+	if len(c) != 1 {
+		return 0, false
+	}
+	switch b := rune(c[0]); {
+	case b >= '0' && b <= '9':
+		return b - '0', true
+	case b >= 'a' && b <= 'f':
+		return b - 'a' + 10, true
+	case b >= 'A' && b <= 'F':
+		return b - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// число может состоять из нескольких разрядов; разбор прекращается, как только
+// оно выходит за пределы, поэтому длинная строка цифр не читается целиком
+func (l *lexer) scanCount(first *cluster) (*token, error) {
+	t := &token{kind: tokenCount, offset: first.offset}
+	for c := first; ; {
+		t.text += c.text
+		if c.text[0] < '0' || c.text[0] > '9' {
+			return nil, &UnpackError{Err: ErrInvalidCount, Offset: t.offset, Snippet: t.text}
+		}
+		if t.count > (math.MaxInt-9)/10 {
+			return nil, &UnpackError{Err: ErrCountOverflow, Offset: t.offset, Snippet: t.text}
+		}
+		t.count = t.count*10 + int(c.text[0]-'0')
+		if max := l.opts.MaxCount; max > 0 && t.count > max {
+			return nil, &UnpackError{Err: &LimitError{Limit: "count", Max: int64(max)}, Offset: t.offset, Snippet: t.text}
+		}
+
+		next, err := l.nextCluster()
+		if err != nil {
+			return nil, err
+		}
+		if next == nil || !digitCluster(next.text) {
+			l.pending = next
+			return t, nil
+		}
+		c = next
+	}
+}
+
+// scanDigits собирает цифры подряд в один обычный символ
+func (l *lexer) scanDigits(first *cluster) (*token, error) {
+	t := &token{kind: tokenSymbol, offset: first.offset}
+	for c := first; ; {
+		t.text += c.text
+		next, err := l.nextCluster()
+		if err != nil {
+			return nil, err
+		}
+		if next == nil || !digitCluster(next.text) {
+			l.pending = next
+			t.value = t.text
+			return t, nil
+		}
+		c = next
+	}
+}
+
+// node - элемент разобранной строки: символ или группа с числом повторений
+type node struct {
+	symbol string
+	group  []*node
+	count  int
+	text   string // исходный текст элемента для сообщений об ошибках
+	offset int
+}
+
+// size возвращает длину развернутого элемента в байтах, не больше math.MaxInt64
+func (n *node) size() int64 {
+	var one int64
+	if n.group == nil {
+		one = int64(len(n.symbol))
+	} else {
+		for _, child := range n.group {
+			one = addSize(one, child.size())
+		}
+	}
+	if one != 0 && int64(n.count) > math.MaxInt64/one {
+		return math.MaxInt64
+	}
+	return one * int64(n.count)
+}
+
+func addSize(a, b int64) int64 {
+	if a > math.MaxInt64-b {
+		return math.MaxInt64
+	}
+	return a + b
+}
+
+// parser - разбор методом рекурсивного спуска
+type parser struct {
+	lex *lexer
+}
+
+// item разбирает элемент с необязательным числом повторений; возвращает nil
+// в конце последовательности
+func (p *parser) item(depth int) (*node, error) {
+	p.lex.digits = p.lex.opts.LiteralDigits
+	t, err := p.lex.peek()
+	p.lex.digits = false
+	if err != nil {
+		return nil, err
+	}
+
+	var n *node
+	switch t.kind {
+	case tokenEOF, tokenClose:
+		return nil, nil
+	case tokenCount:
+		// число в начале строки или группы не к чему применить
+		return nil, &UnpackError{Err: ErrLeadingDigit, Offset: t.offset, Snippet: t.text}
+	case tokenSymbol:
+		p.lex.next()
+		n = &node{symbol: t.value, text: t.text, offset: t.offset}
+	case tokenOpen:
+		if n, err = p.group(depth + 1); err != nil {
+			return nil, err
+		}
+	}
+
+	n.count = 1
+	if t, err = p.lex.peek(); err != nil {
+		return nil, err
+	}
+	if t.kind == tokenCount {
+		p.lex.next()
+		switch {
+		case t.count == 0 && p.lex.opts.DisallowZero:
+			return nil, &UnpackError{Err: ErrZeroCount, Offset: t.offset, Snippet: t.text}
+		case t.count == 1 && p.lex.opts.Strict:
+			return nil, &UnpackError{Err: ErrRedundantCount, Offset: t.offset, Snippet: t.text}
+		}
+		n.count = t.count
+		n.text += t.text
+	}
+	return n, nil
+}
+
+// group разбирает "(" sequence ")"
+func (p *parser) group(depth int) (*node, error) {
+	open, _ := p.lex.next()
+	if depth > maxGroupDepth {
+		return nil, &UnpackError{Err: ErrGroupDepth, Offset: open.offset, Snippet: open.text}
+	}
+
+	n := &node{group: []*node{}, offset: open.offset}
+	var text strings.Builder
+	text.WriteString(open.text)
+	for {
+		child, err := p.item(depth)
+		if err != nil {
+			return nil, err
+		}
+		if child == nil {
+			break
+		}
+		// пустые элементы вроде ()N или (a0)N ничего не выводят, но их
+		// повторения во вложенных группах перемножаются - не храним их
+		if child.size() != 0 {
+			n.group = append(n.group, child)
+		}
+		text.WriteString(child.text)
+	}
+
+	t, err := p.lex.next()
+	if err != nil {
+		return nil, err
+	}
+	if t.kind != tokenClose {
+		return nil, &UnpackError{Err: ErrUnclosedGroup, Offset: open.offset, Snippet: open.text}
+	}
+	text.WriteString(t.text)
+	n.text = text.String()
+	return n, nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits ограничивает распаковку недоверенных строк. Нулевое значение поля
+// означает отсутствие ограничения.
+type Limits struct {
+	MaxCount  int   // наибольшее число повторений одного символа
+	MaxOutput int64 // наибольший размер результата в байтах
+}
+
+// Unpacker распаковывает строки с настраиваемым диалектом формата. Нулевое
+// значение соответствует resolveString: число 0 удаляет элемент, цифра в
+// начале строки или группы - ошибка, символ экранирования - \.
+type Unpacker struct {
+	Limits
+	DisallowZero  bool // число 0 - ошибка
+	LiteralDigits bool // цифры в начале строки или группы - обычные символы
+	Escape        rune // символ экранирования, 0 означает \
+	Strict        bool // явное число 1 - ошибка
+}
+
+// Unpack читает упакованную строку из r и записывает результат в w, не
+// собирая его в памяти целиком: в памяти держится только текущий элемент
+// верхнего уровня. Возвращает число записанных байт.
+func (u Unpacker) Unpack(w io.Writer, r io.Reader) (int64, error) {
+	if u.Escape == 0 {
+		u.Escape = '\\'
+	}
+	if u.Escape == '(' || u.Escape == ')' || unicode.IsDigit(u.Escape) || !utf8.ValidRune(u.Escape) {
+		return 0, ErrEscapeChar
+	}
+
+	e := &expander{w: bufio.NewWriter(w), limits: u.Limits}
+	err := e.run(&parser{lex: &lexer{r: bufio.NewReader(r), opts: u, escape: string(u.Escape)}})
+	if flushErr := e.w.Flush(); err == nil {
+		err = flushErr
+	}
+	return e.written, err
+}
+
+// UnpackString распаковывает строку целиком.
+func (u Unpacker) UnpackString(s string) (string, error) {
+	var out strings.Builder
+	if _, err := u.Unpack(&out, strings.NewReader(s)); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// Unpack распаковывает поток из r в w по правилам по умолчанию с заданными
+// ограничениями.
+func Unpack(w io.Writer, r io.Reader, limits Limits) (int64, error) {
+	return Unpacker{Limits: limits}.Unpack(w, r)
+}
+
+// expander записывает развернутые элементы с учетом ограничений
+type expander struct {
+	w       *bufio.Writer
+	limits  Limits
+	written int64
+}
+
+func (e *expander) run(p *parser) error {
+	for {
+		n, err := p.item(0)
+		if err != nil {
+			return err
+		}
+		if n == nil {
+			break
+		}
+		size := n.size()
+		if size == 0 {
+			continue
+		}
+		// размер проверяется до записи, чтобы не выдавать обрезанный результат
+		if max := e.limits.MaxOutput; max > 0 && size > max-e.written {
+			return &UnpackError{Err: &LimitError{Limit: "output", Max: max}, Offset: n.offset, Snippet: n.text}
+		}
+		if err := e.write(n); err != nil {
+			return err
+		}
+	}
+
+	t, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	if t.kind == tokenClose {
+		return &UnpackError{Err: ErrUnmatchedParen, Offset: t.offset, Snippet: t.text}
+	}
+	return nil
+}
+
+// запись элемента count раз
+func (e *expander) write(n *node) error {
+	for i := 0; i < n.count; i++ {
+		if n.group == nil {
+			if _, err := e.w.WriteString(n.symbol); err != nil {
+				return err
+			}
+			e.written += int64(len(n.symbol))
+			continue
+		}
+		for _, child := range n.group {
+			if err := e.write(child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// unpackString распаковывает строку целиком с заданными ограничениями
+func unpackString(s string, limits Limits) (string, error) {
+	return Unpacker{Limits: limits}.UnpackString(s)
+}
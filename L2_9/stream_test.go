@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestUnpackLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		limits   Limits
+		expected string
+		limit    string // какое ограничение должно сработать
+	}{
+		{name: "без ограничений", input: "a4bc2d5e", expected: "aaaabccddddde"},
+		{name: "число на границе", input: "a10", limits: Limits{MaxCount: 10}, expected: "aaaaaaaaaa"},
+		{name: "число больше", input: "a11", limits: Limits{MaxCount: 10}, limit: "count"},
+		{name: "огромное число", input: "a999999999999999999999999", limits: Limits{MaxCount: 1000}, limit: "count"},
+		{name: "размер на границе", input: "ж5", limits: Limits{MaxOutput: 10}, expected: "жжжжж"},
+		{name: "размер больше", input: "ж5x", limits: Limits{MaxOutput: 10}, limit: "output"},
+		{name: "бомба", input: "a999999999", limits: Limits{MaxOutput: 1 << 20}, limit: "output"},
+		{name: "удаление не считается", input: "ab0c", limits: Limits{MaxOutput: 2}, expected: "ac"},
+		// пустые группы не повторяются, даже вложенные
+		{name: "пустая группа", input: "()1000x", limits: Limits{MaxCount: 1000, MaxOutput: 1000}, expected: "x"},
+		{name: "группа без вывода", input: "(a0)1000x", limits: Limits{MaxCount: 1000, MaxOutput: 1000}, expected: "x"},
+		{name: "вложенные пустые группы", input: "((((()1000)1000)1000)1000)1000",
+			limits: Limits{MaxCount: 1000, MaxOutput: 1000}},
+		{name: "вложенные без ограничений", input: "(((b0)999999999)999999999)999999999a", expected: "a"},
+		{name: "пустое внутри непустой", input: "(a((b0)999)999)2", limits: Limits{MaxOutput: 2}, expected: "aa"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			n, err := Unpack(&out, strings.NewReader(tt.input), tt.limits)
+			if tt.limit != "" {
+				var limitErr *LimitError
+				if !errors.As(err, &limitErr) || limitErr.Limit != tt.limit {
+					t.Fatalf("Expected %s limit error, got %v (input: %q)", tt.limit, err, tt.input)
+				}
+				if tt.limits.MaxOutput > 0 && n > tt.limits.MaxOutput {
+					t.Errorf("Wrote %d bytes over the limit of %d", n, tt.limits.MaxOutput)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v (input: %q)", err, tt.input)
+			}
+			if out.String() != tt.expected || n != int64(len(tt.expected)) {
+				t.Errorf("Expected %q, got %q (%d bytes)", tt.expected, out.String(), n)
+			}
+		})
+	}
+}
+
+func TestUnpackBufferBoundaries(t *testing.T) {
+	inputs := []string{
+		"a4bc2d5e", "a10b", "л12к5", `qwe\45`, "ё12", "👩\u200d💻10", "🇷🇺3", "ab0c", "a0000000000003",
+	}
+	readers := map[string]func(io.Reader) io.Reader{
+		"one byte": iotest.OneByteReader,
+		"half":     iotest.HalfReader,
+		"data err": iotest.DataErrReader,
+	}
+
+	for _, input := range inputs {
+		expected, err := resolveString(input)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v (input: %q)", err, input)
+		}
+		for name, wrap := range readers {
+			t.Run(name+" "+input, func(t *testing.T) {
+				var out strings.Builder
+				if _, err := Unpack(&out, wrap(strings.NewReader(input)), Limits{}); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if out.String() != expected {
+					t.Errorf("Expected %q, got %q", expected, out.String())
+				}
+			})
+		}
+	}
+}
+
+func TestUnpackReadError(t *testing.T) {
+	r := io.MultiReader(strings.NewReader("a3"), iotest.ErrReader(io.ErrUnexpectedEOF))
+	if _, err := Unpack(io.Discard, r, Limits{}); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected read error, got %v", err)
+	}
+}
+
+func TestUnpacker(t *testing.T) {
+	tests := []struct {
+		name     string
+		unpacker Unpacker
+		input    string
+		expected string
+		err      error
+	}{
+		{name: "по умолчанию", input: `a4b0(cd)2\3`, expected: "aaaacdcd3"},
+		{name: "по умолчанию цифра в начале", input: "4a", err: ErrLeadingDigit},
+		{name: "запрет нуля", unpacker: Unpacker{DisallowZero: true}, input: "ab0c", err: ErrZeroCount},
+		{name: "запрет нуля из нескольких цифр", unpacker: Unpacker{DisallowZero: true}, input: "a00", err: ErrZeroCount},
+		{name: "запрет нуля, число с нулем", unpacker: Unpacker{DisallowZero: true}, input: "a10", expected: "aaaaaaaaaa"},
+		{name: "цифры в начале строки", unpacker: Unpacker{LiteralDigits: true}, input: "45a3", expected: "45aaa"},
+		{name: "цифры в начале группы", unpacker: Unpacker{LiteralDigits: true}, input: "(1b)2", expected: "1b1b"},
+		{name: "цифры после символа - число", unpacker: Unpacker{LiteralDigits: true}, input: "a12", expected: "aaaaaaaaaaaa"},
+		{name: "свой символ экранирования", unpacker: Unpacker{Escape: '/'}, input: `/3\2/n`, expected: "3\\\\\n"},
+		{name: "обрыв своего экранирования", unpacker: Unpacker{Escape: '%'}, input: "ab%", err: ErrDanglingEscape},
+		{name: "цифра как экранирование", unpacker: Unpacker{Escape: '7'}, input: "a", err: ErrEscapeChar},
+		{name: "скобка как экранирование", unpacker: Unpacker{Escape: '('}, input: "a", err: ErrEscapeChar},
+		{name: "строгий режим", unpacker: Unpacker{Strict: true}, input: "a2b1", err: ErrRedundantCount},
+		{name: "строгий режим, 01", unpacker: Unpacker{Strict: true}, input: "a01", err: ErrRedundantCount},
+		{name: "строгий режим без лишних единиц", unpacker: Unpacker{Strict: true}, input: "a2b11", expected: "aabbbbbbbbbbb"},
+		{name: "ограничения", unpacker: Unpacker{Limits: Limits{MaxCount: 5}}, input: "a6", err: ErrLimitExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := tt.unpacker.UnpackString(tt.input)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("Expected %v, got %q, %v", tt.err, res, err)
+				}
+				return
+			}
+			if err != nil || res != tt.expected {
+				t.Errorf("Expected %q, got %q, %v", tt.expected, res, err)
+			}
+		})
+	}
+}